@@ -51,12 +51,19 @@ func (p *Program) String() string {
 
 // FunctionDecl represents a function declaration in the abstract syntax tree (AST).
 // It contains the function's name token, return type, name, list of parameters, and the function body.
+// Body is nil for a declaration without a definition, such as the prototype int max(int, int);.
+// Prototyped is set when the parameter types are declared in the parameter list, or by an
+// earlier prototype of the same function, so that calls can be checked against them; it is
+// clear for int f() and for old-style definitions. Variadic is set when the parameter list
+// ends with "...".
 type FunctionDecl struct {
 	Token      Token // the function name token
 	ReturnType string
 	Name       string
 	Parameters []*Parameter
 	Body       *BlockStatement
+	Prototyped bool
+	Variadic   bool
 }
 
 func (fd *FunctionDecl) statementNode()       {}
@@ -71,12 +78,15 @@ type Parameter struct {
 
 // VarDecl represents a variable declaration in the abstract syntax tree (AST).
 // It contains the token associated with the declaration, the variable's type,
-// its name, and an optional initial value expression.
+// its name, and an optional initial value expression. Storage is "static" or
+// "extern" for a variable declared with that storage class inside a function,
+// and empty otherwise.
 type VarDecl struct {
-	Token Token
-	Type  string
-	Name  string
-	Value Expression
+	Token   Token
+	Type    string
+	Name    string
+	Value   Expression
+	Storage string
 }
 
 func (vd *VarDecl) statementNode()       {}
@@ -151,6 +161,20 @@ func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
 func (ws *WhileStatement) String() string       { return "while" }
 
 
+// DoWhileStatement represents a 'do ... while' loop statement in the AST.
+// It contains the token for the 'do' keyword, the body of the loop as a block
+// statement, and the loop condition, which is tested after each pass through the body.
+type DoWhileStatement struct {
+	Token     Token
+	Body      *BlockStatement
+	Condition Expression
+}
+
+func (ds *DoWhileStatement) statementNode()       {}
+func (ds *DoWhileStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DoWhileStatement) String() string       { return "do-while" }
+
+
 // ForStatement represents a 'for' loop construct in the AST.
 // It contains the initial statement (Init), loop condition (Condition),
 // post-iteration expression (Post), and the loop body (Body).
@@ -168,6 +192,39 @@ func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
 func (fs *ForStatement) String() string       { return "for" }
 
 
+// SwitchStatement represents a 'switch' statement in the AST.
+// It contains the token for the 'switch' keyword, the controlling expression,
+// and the body, whose statements include the CaseStatement labels that control
+// where execution starts.
+type SwitchStatement struct {
+	Token Token
+	Value Expression
+	Body  *BlockStatement
+}
+
+func (ss *SwitchStatement) statementNode()       {}
+func (ss *SwitchStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *SwitchStatement) String() string       { return "switch" }
+
+
+// CaseStatement represents a 'case' or 'default' label inside a switch body.
+// Value holds the constant expression of a 'case' label and is nil for 'default'.
+// Executing a label does nothing, so control falls through into the statements after it.
+type CaseStatement struct {
+	Token Token
+	Value Expression
+}
+
+func (cs *CaseStatement) statementNode()       {}
+func (cs *CaseStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *CaseStatement) String() string {
+	if cs.Value == nil {
+		return "default:"
+	}
+	return "case " + cs.Value.String() + ":"
+}
+
+
 // BreakStatement represents a 'break' statement in the abstract syntax tree (AST).
 // It contains the token associated with the 'break' keyword.
 type BreakStatement struct {
@@ -190,6 +247,32 @@ func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
 func (cs *ContinueStatement) String() string       { return "continue" }
 
 
+// LabelStatement represents a 'name:' label in the AST, the target of a goto.
+// Executing a label does nothing; it only marks a position in the function body.
+type LabelStatement struct {
+	Token Token
+	Name  string
+}
+
+func (ls *LabelStatement) statementNode()       {}
+func (ls *LabelStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LabelStatement) String() string       { return ls.Name + ":" }
+
+
+// GotoStatement represents a 'goto' statement in the AST.
+// It holds the token for the 'goto' keyword, the name of the target label, and
+// the label itself, which the parser fills in once the whole function is parsed.
+type GotoStatement struct {
+	Token  Token
+	Label  string
+	Target *LabelStatement
+}
+
+func (gs *GotoStatement) statementNode()       {}
+func (gs *GotoStatement) TokenLiteral() string { return gs.Token.Literal }
+func (gs *GotoStatement) String() string       { return "goto " + gs.Label }
+
+
 // Identifier represents an identifier node in the abstract syntax tree (AST).
 // It holds the token associated with the identifier and its string value.
 type Identifier struct {
@@ -203,10 +286,13 @@ func (i *Identifier) String() string       { return i.Value }
 
 
 // IntegerLiteral represents an integer constant in the abstract syntax tree (AST).
-// It holds the token associated with the literal and its integer value.
+// It holds the token associated with the literal, its integer value, and its type,
+// which follows from the constant's value, base and suffix as in C: 10 is an int,
+// 10UL an unsigned long and 0xFFFFFFFF an unsigned int.
 type IntegerLiteral struct {
 	Token Token
 	Value int64
+	Type  string
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
@@ -215,10 +301,12 @@ func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
 
 // FloatLiteral represents a floating-point literal in the abstract syntax tree (AST).
-// It contains the token associated with the literal and its float64 value.
+// It contains the token associated with the literal, its float64 value, and its type:
+// "float" for a constant with an F suffix such as 0.5f, and "double" otherwise.
 type FloatLiteral struct {
 	Token Token
 	Value float64
+	Type  string
 }
 
 func (fl *FloatLiteral) expressionNode()      {}
@@ -227,7 +315,8 @@ func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
 
 
 // StringLiteral represents a string literal in the abstract syntax tree (AST).
-// It contains the token associated with the literal and its string value.
+// It contains the token associated with the literal and its string value, with escape
+// sequences decoded and adjacent literals such as "ab" "cd" joined into one.
 type StringLiteral struct {
 	Token Token
 	Value string
@@ -239,7 +328,8 @@ func (sl *StringLiteral) String() string       { return sl.Value }
 
 
 // CharLiteral represents a character literal in the abstract syntax tree (AST).
-// It contains the token associated with the literal and its byte value.
+// It contains the token associated with the literal and its byte value, with any
+// escape sequence decoded, so that '\n' holds 10.
 type CharLiteral struct {
 	Token Token
 	Value byte
@@ -347,3 +437,106 @@ type ConditionalExpression struct {
 func (ce *ConditionalExpression) expressionNode()      {}
 func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
 func (ce *ConditionalExpression) String() string       { return "(...? ... : ...)" }
+
+
+// CastExpression represents an explicit type conversion such as (double)sum in the AST.
+// Type is the type named in parentheses and Right is the operand being converted.
+type CastExpression struct {
+	Token Token
+	Type  string
+	Right Expression
+}
+
+func (ce *CastExpression) expressionNode()      {}
+func (ce *CastExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CastExpression) String() string {
+	return "((" + ce.Type + ")" + ce.Right.String() + ")"
+}
+
+
+// SizeofExpression represents a sizeof operator in the AST. For sizeof(type) the
+// Type field holds the type name; for sizeof applied to an expression, Operand holds
+// the expression, which is never evaluated.
+type SizeofExpression struct {
+	Token   Token
+	Type    string
+	Operand Expression
+}
+
+func (se *SizeofExpression) expressionNode()      {}
+func (se *SizeofExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SizeofExpression) String() string {
+	if se.Operand != nil {
+		return "sizeof(" + se.Operand.String() + ")"
+	}
+	return "sizeof(" + se.Type + ")"
+}
+
+
+// InitializerList represents a brace-enclosed list of initializers, such as {1, 2, 3},
+// used to give an array, struct or union its initial contents. Elements may themselves
+// be InitializerLists for nested arrays and members, or may omit their braces as C allows.
+type InitializerList struct {
+	Token    Token
+	Elements []Expression
+}
+
+func (il *InitializerList) expressionNode()      {}
+func (il *InitializerList) TokenLiteral() string { return il.Token.Literal }
+func (il *InitializerList) String() string       { return "{...}" }
+
+
+// StructDecl represents a struct or union definition, such as struct point { int x; int y; }.
+// Kind is either "struct" or "union", and Name is the tag; anonymous definitions are given
+// a generated tag so that they can be referred to by type.
+type StructDecl struct {
+	Token  Token // the struct or union keyword token
+	Kind   string
+	Name   string
+	Fields []*StructField
+}
+
+func (sd *StructDecl) statementNode()       {}
+func (sd *StructDecl) TokenLiteral() string { return sd.Token.Literal }
+func (sd *StructDecl) String() string       { return sd.Kind + " " + sd.Name + " {...}" }
+
+// TypeName returns the type string used to refer to the struct or union, such as "struct point".
+func (sd *StructDecl) TypeName() string { return sd.Kind + " " + sd.Name }
+
+// StructField represents a single member of a struct or union with its type and name.
+type StructField struct {
+	Type string
+	Name string
+}
+
+// DeclarationList represents a single declaration that produces several statements,
+// such as a struct definition together with a variable of that type:
+// struct point { int x; int y; } origin;
+type DeclarationList struct {
+	Token        Token
+	Declarations []Statement
+}
+
+func (dl *DeclarationList) statementNode()       {}
+func (dl *DeclarationList) TokenLiteral() string { return dl.Token.Literal }
+func (dl *DeclarationList) String() string {
+	out := ""
+	for _, d := range dl.Declarations {
+		out += d.String() + "; "
+	}
+	return out
+}
+
+
+// MemberExpression represents access to a struct or union member, either directly
+// with '.' (s.x) or through a pointer with '->' (p->x).
+type MemberExpression struct {
+	Token    Token // the '.' or '->' token
+	Left     Expression
+	Operator string
+	Member   string
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string       { return me.Left.String() + me.Operator + me.Member }
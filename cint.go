@@ -1,22 +1,76 @@
 package cint
 
+import "io/fs"
+
 // Cint represents a wrapper around an Interpreter instance, providing methods and state
 // for interacting with the interpreter in the context of the cint package.
 type Cint struct {
 	interpreter *Interpreter
 }
 
+// Option configures how New prepares a program.
+type Option func(*options)
+
+// options holds the settings made by the Options passed to New.
+type options struct {
+	fsys        fs.FS
+	includeDirs []string
+	defines     [][2]string
+}
+
+// WithFS makes the files in fsys available to #include. The source passed to New
+// is treated as a file in the root directory of fsys, so a file it includes in
+// quotes is looked for there first.
+func WithFS(fsys fs.FS) Option {
+	return func(o *options) {
+		o.fsys = fsys
+	}
+}
+
+// WithIncludeDirs adds directories of the file system given by WithFS to the list
+// searched by #include, in order, like the -I option of a C compiler. A file named
+// in angle brackets, such as <stdio.h>, is looked for only in these directories
+// and then among the headers built into cint.
+func WithIncludeDirs(dirs ...string) Option {
+	return func(o *options) {
+		o.includeDirs = append(o.includeDirs, dirs...)
+	}
+}
+
+// WithDefine predefines a macro for the program, like the -D option of a C compiler:
+// WithDefine("DEBUG", "1") has the effect of "#define DEBUG 1" before the first line
+// of source. The name may include a parameter list to define a function-like macro.
+func WithDefine(name, value string) Option {
+	return func(o *options) {
+		o.defines = append(o.defines, [2]string{name, value})
+	}
+}
+
 // New creates a new instance of Cint by parsing the provided source string.
-// It initializes the lexer, parser, and interpreter for the given source code.
-// If parsing errors are encountered, it returns a ParseError containing the errors.
+// It initializes the lexer, preprocessor, parser, and interpreter for the given source code.
+// Any options given predefine macros and control where #include finds files; without
+// them only the headers built into cint, such as <stdio.h> and <stdlib.h>, can be included.
+// If preprocessing or parsing errors are encountered, it returns a ParseError containing the errors.
 // On success, it returns a pointer to the initialized Cint and a nil error.
-func New(source string) (*Cint, error) {
+func New(source string, opts ...Option) (*Cint, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	lexer := NewLexer(source)
-	parser := NewParser(lexer)
+	preprocessor := NewPreprocessor(lexer)
+	preprocessor.SetFS(o.fsys, o.includeDirs...)
+	for _, d := range o.defines {
+		if err := preprocessor.Define(d[0], d[1]); err != nil {
+			return nil, err
+		}
+	}
+	parser := NewParser(preprocessor)
 	program := parser.ParseProgram()
 
-	if len(parser.Errors()) > 0 {
-		return nil, &ParseError{Errors: parser.Errors()}
+	if errors := append(preprocessor.Errors(), parser.Errors()...); len(errors) > 0 {
+		return nil, &ParseError{Errors: errors}
 	}
 
 	interpreter := NewInterpreter(program)
@@ -2,6 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing/fstest"
 
 	"github.com/misterunix/cint"
 )
@@ -212,6 +216,1620 @@ func testOperators() bool {
 	return true
 }
 
+func testScoping() bool {
+	source := `
+	int x = 1;
+
+	int main() {
+		int x = 2;
+		int i = 100;
+		int n = 0;
+
+		{
+			int x = 3;
+			if (x != 3) {
+				return 1;
+			}
+		}
+		if (x != 2) {
+			return 2;
+		}
+
+		if (x == 2) {
+			int x = 4;
+			x++;
+		} else {
+			int x = 5;
+		}
+		if (x != 2) {
+			return 3;
+		}
+
+		while (n < 3) {
+			int x = n;
+			int i = x * 10;
+			n++;
+		}
+		if (x != 2 || i != 100) {
+			return 4;
+		}
+
+		for (int i = 0; i < 3; i++) {
+			int x = i;
+		}
+		if (x != 2 || i != 100) {
+			return 5;
+		}
+
+		do {
+			int x = 6;
+			n = x;
+		} while (0);
+		switch (n) {
+		case 6:
+			{
+				int x = 7;
+			}
+			int i = 8;
+			n = i;
+		}
+		if (x != 2 || i != 100 || n != 8) {
+			return 6;
+		}
+
+		return 0;
+	}
+	`
+	return runChecks("Scoping", source)
+}
+
+// runChecks runs source, whose main returns the number of the first check that
+// failed or 0 if all passed, and reports the outcome under name.
+func runChecks(name, source string, opts ...cint.Option) bool {
+	interp, err := cint.New(source, opts...)
+	if err == nil {
+		err = stepChecks(interp)
+	}
+	if err != nil {
+		fmt.Printf("❌ %s test failed: %v\n", name, err)
+		return false
+	}
+
+	fmt.Printf("✅ %s test passed\n", name)
+	return true
+}
+
+// stepChecks single-steps interp until main returns, and reports an error if a
+// step fails or main returns anything but 0, the number of the failed check.
+func stepChecks(interp *cint.Cint) error {
+	interp.EnableSingleStep()
+	for {
+		result := interp.Step()
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.Returned {
+			if result.ReturnVal == nil {
+				return fmt.Errorf("main returned no value")
+			}
+			if result.ReturnVal.Int != 0 {
+				return fmt.Errorf("check %d failed", result.ReturnVal.Int)
+			}
+			return nil
+		}
+		if result.Done {
+			return nil
+		}
+	}
+}
+
+// runOutput runs source and checks that what it prints to standard output is want.
+func runOutput(name, source, want string) bool {
+	interp, err := cint.New(source)
+	if err != nil {
+		fmt.Printf("❌ %s test failed: %v\n", name, err)
+		return false
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Printf("❌ %s test failed: %v\n", name, err)
+		return false
+	}
+	captured := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		captured <- string(data)
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	err = interp.Run()
+	os.Stdout = stdout
+	w.Close()
+	got := <-captured
+
+	if err != nil {
+		fmt.Printf("❌ %s test failed: %v\n", name, err)
+		return false
+	}
+	if got != want {
+		fmt.Printf("❌ %s test failed: printed %q, want %q\n", name, got, want)
+		return false
+	}
+
+	fmt.Printf("✅ %s test passed\n", name)
+	return true
+}
+
+// runError checks that source is rejected, either by the parser or when it runs,
+// with an error mentioning want.
+func runError(name, source, want string, opts ...cint.Option) bool {
+	interp, err := cint.New(source, opts...)
+	if err == nil {
+		err = stepChecks(interp)
+	}
+	if err == nil {
+		fmt.Printf("❌ %s test failed: no error reported\n", name)
+		return false
+	}
+	if !strings.Contains(err.Error(), want) {
+		fmt.Printf("❌ %s test failed: got %v, want %q\n", name, err, want)
+		return false
+	}
+
+	fmt.Printf("✅ %s test passed\n", name)
+	return true
+}
+
+func testUnsignedConversions() bool {
+	source := `
+	int main() {
+		unsigned long max = 0xFFFFFFFFFFFFFFFFUL;
+		unsigned long big = 1.8e19;
+		unsigned long half = 9223372036854775808.0;
+		double d = max;
+
+		if ((double)max != 18446744073709551615.0) {
+			return 1;
+		}
+		if (d < 0 || max / 2.0 != 9223372036854775808.0) {
+			return 2;
+		}
+		if (big != 18000000000000000000UL) {
+			return 3;
+		}
+		if (half != 9223372036854775808UL || (unsigned long)(d / 4) != 4611686018427387904UL) {
+			return 4;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Unsigned conversions", source)
+}
+
+func testGotoIntoBlock() bool {
+	source := `
+	int main() {
+		int n = 0;
+
+		goto inner;
+		{
+			int y = 1;
+		inner:
+			y = 2;
+			n = y;
+		}
+		if (n != 2) {
+			return 1;
+		}
+
+		goto end;
+		int z = 7;
+	end:
+		z = 9;
+		if (z != 9) {
+			return 2;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Goto into block", source)
+}
+
+func testArrays() bool {
+	source := `
+	int main() {
+		int a[10];
+		int m[3][4];
+		int b[] = {1, 2, 3};
+		int z[5] = {7};
+		int i, j;
+
+		for (i = 0; i < 10; i++) {
+			a[i] = i * i;
+		}
+		if (a[3] != 9 || a[9] != 81) {
+			return 1;
+		}
+
+		for (i = 0; i < 3; i++) {
+			for (j = 0; j < 4; j++) {
+				m[i][j] = i * 10 + j;
+			}
+		}
+		if (m[2][3] != 23 || m[1][0] != 10 || m[0][3] != 3) {
+			return 2;
+		}
+
+		if (sizeof b != 3 * sizeof(int) || b[0] + b[1] + b[2] != 6) {
+			return 3;
+		}
+		if (z[0] != 7 || z[1] != 0 || z[4] != 0) {
+			return 4;
+		}
+
+		a[b[2]] += 1;
+		if (a[3] != 10) {
+			return 5;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Arrays", source)
+}
+
+func testPointers() bool {
+	source := `
+	void swap(int *a, int *b) {
+		int t = *a;
+		*a = *b;
+		*b = t;
+	}
+
+	void divide(int n, int d, int *quot, int *rem) {
+		*quot = n / d;
+		*rem = n % d;
+	}
+
+	int main() {
+		int x = 1, y = 2;
+		int *p = &x;
+		int **pp = &p;
+		int q, r;
+
+		swap(&x, &y);
+		if (x != 2 || y != 1) {
+			return 1;
+		}
+
+		*p = 5;
+		if (x != 5 || *p != 5) {
+			return 2;
+		}
+
+		**pp = 6;
+		*pp = &y;
+		*p += 10;
+		if (x != 6 || y != 11) {
+			return 3;
+		}
+
+		divide(17, 5, &q, &r);
+		if (q != 3 || r != 2) {
+			return 4;
+		}
+
+		p = 0;
+		if (p != 0 || &x == &y) {
+			return 5;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Pointers", source)
+}
+
+func testPointerArithmetic() bool {
+	source := `
+	int length(char *s) {
+		char *p = s;
+		while (*p++) {
+		}
+		return p - s - 1;
+	}
+
+	int main() {
+		int a[5] = {10, 20, 30, 40, 50};
+		int m[2][3] = {{1, 2, 3}, {4, 5, 6}};
+		int *p = a;
+		int *q = &a[4];
+		int (*row)[3] = m;
+		int *cols[2];
+
+		if (*(p + 2) != 30 || p[3] != 40 || 2[p] != 30) {
+			return 1;
+		}
+		if (q - p != 4 || !(p < q) || p + 4 != q) {
+			return 2;
+		}
+		p++;
+		q -= 2;
+		if (*p != 20 || *q != 30 || q - p != 1) {
+			return 3;
+		}
+		if (length("hello") != 5) {
+			return 4;
+		}
+
+		row++;
+		if ((*row)[0] != 4 || row[0][2] != 6 || sizeof *row != 3 * sizeof(int)) {
+			return 5;
+		}
+		cols[0] = &m[0][1];
+		cols[1] = m[1];
+		if (*cols[0] != 2 || cols[1][2] != 6 || sizeof cols != 2 * sizeof(int *)) {
+			return 6;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Pointer arithmetic", source)
+}
+
+func testStructs() bool {
+	source := `
+	struct point {
+		int x;
+		int y;
+	};
+
+	struct rect {
+		struct point min, max;
+	};
+
+	struct node {
+		int value;
+		struct node *next;
+	};
+
+	union word {
+		unsigned int u;
+		unsigned char bytes[4];
+	};
+
+	struct point offset(struct point p, int d) {
+		p.x += d;
+		p.y += d;
+		return p;
+	}
+
+	int area(struct rect *r) {
+		return (r->max.x - r->min.x) * (r->max.y - r->min.y);
+	}
+
+	int main() {
+		struct point a = {1, 2};
+		struct point b;
+		struct rect r = {{0, 0}, {4, 3}};
+		struct node n3 = {3, 0}, n2 = {2, &n3}, n1 = {1, &n2};
+		struct node *n;
+		union word w;
+		int sum = 0;
+
+		b = offset(a, 10);
+		if (a.x != 1 || a.y != 2 || b.x != 11 || b.y != 12) {
+			return 1;
+		}
+
+		if (area(&r) != 12) {
+			return 2;
+		}
+		r.max = b;
+		r.min.x = 1;
+		if (r.max.y != 12 || area(&r) != 120) {
+			return 3;
+		}
+
+		for (n = &n1; n != 0; n = n->next) {
+			sum += n->value;
+		}
+		if (sum != 6) {
+			return 4;
+		}
+
+		w.u = 0x01020304;
+		if (w.bytes[0] != 4 || w.bytes[3] != 1 || sizeof w != 4) {
+			return 5;
+		}
+		w.bytes[0] = 0xFF;
+		if (w.u != 0x010203FF) {
+			return 6;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Structs and unions", source)
+}
+
+func testEnums() bool {
+	source := `
+	enum color { RED, GREEN = 5, BLUE };
+	enum { SMALL = 2, LARGE = SMALL * 4 };
+
+	int weight(enum color c) {
+		return c * 10;
+	}
+
+	int main() {
+		enum color c = BLUE;
+		int table[LARGE];
+
+		if (RED != 0 || GREEN != 5 || BLUE != 6) {
+			return 1;
+		}
+		if (c != 6 || weight(GREEN) != 50) {
+			return 2;
+		}
+		if (sizeof table != 8 * sizeof(int)) {
+			return 3;
+		}
+		c = RED;
+		c++;
+		if (c != 1) {
+			return 4;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Enums", source)
+}
+
+func testTypedefs() bool {
+	source := `
+	typedef unsigned long size;
+	typedef struct node {
+		int value;
+		struct node *next;
+	} Node, *NodePtr;
+	typedef int Vector[3];
+
+	int total(NodePtr n) {
+		int sum = 0;
+		for (; n; n = n->next) {
+			sum += n->value;
+		}
+		return sum;
+	}
+
+	int main() {
+		Node b = {2, 0};
+		Node a = {1, &b};
+		Vector v = {1, 2, 3};
+		size n = sizeof(Vector);
+
+		if (total(&a) != 3 || n != 12 || v[2] != 3) {
+			return 1;
+		}
+		if ((size)-1 != 18446744073709551615UL || sizeof(NodePtr) != 8) {
+			return 2;
+		}
+
+		{
+			int size = 4;
+			size = size * 2;
+			if (size != 8) {
+				return 3;
+			}
+		}
+		if (sizeof(size) != 8) {
+			return 4;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Typedefs", source)
+}
+
+func testSwitch() bool {
+	source := `
+	int classify(int n) {
+		int r = 0;
+		switch (n) {
+		default:
+			r = r + 100;
+		case 1:
+			r = r + 1;
+			break;
+		case 2:
+		case 3:
+			r = r + 2;
+		case 4:
+			r = r + 4;
+		}
+		return r;
+	}
+
+	int copy(int *to, int *from, int count) {
+		int n = (count + 3) / 4;
+		switch (count % 4) {
+		case 0: do { *to++ = *from++;
+		case 3:      *to++ = *from++;
+		case 2:      *to++ = *from++;
+		case 1:      *to++ = *from++;
+			} while (--n > 0);
+		}
+		return n;
+	}
+
+	int main() {
+		if (classify(1) != 1 || classify(2) != 6 || classify(4) != 4 || classify(9) != 101) {
+			return 1;
+		}
+
+		unsigned x = 4294967295u;
+		switch (x) {
+		case -1:
+			break;
+		default:
+			return 2;
+		}
+
+		switch (1) {
+		case 0:
+			{
+			case 1:
+				x = 5;
+			}
+		}
+		if (x != 5) {
+			return 3;
+		}
+
+		int from[10];
+		int to[10];
+		int i;
+		for (i = 0; i < 10; i++) {
+			from[i] = i + 1;
+			to[i] = 0;
+		}
+		copy(to, from, 10);
+		for (i = 0; i < 10; i++) {
+			if (to[i] != i + 1) {
+				return 4;
+			}
+		}
+
+		int hits = 0;
+		switch (2) {
+		case 1:
+			hits = 10;
+		case 2:
+			switch (7) {
+			case 2:
+				return 5;
+			case 7:
+				hits++;
+				break;
+			}
+			hits++;
+		}
+		if (hits != 2) {
+			return 6;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Switch", source) &&
+		runError("Duplicate case", "int main() { unsigned x = 1; switch (x) { case 4294967295u: case -1: break; } return 0; }", "duplicate case value") &&
+		runError("Case outside switch", "int main() { case 1: return 0; }", "not within a switch statement")
+}
+
+func testDoWhile() bool {
+	source := `
+	int main() {
+		int n = 0;
+		do {
+			n++;
+		} while (0);
+		if (n != 1) {
+			return 1;
+		}
+
+		int i = 0;
+		int sum = 0;
+		do {
+			i++;
+			if (i % 2 == 0) {
+				continue;
+			}
+			if (i > 7) {
+				break;
+			}
+			sum += i;
+		} while (i < 10);
+		if (sum != 16 || i != 9) {
+			return 2;
+		}
+
+		int rows = 0;
+		int r = 0;
+		do {
+			int c = 0;
+			do {
+				rows++;
+				c++;
+			} while (c < 3);
+			r++;
+		} while (r < 2);
+		if (rows != 6) {
+			return 3;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Do-while", source)
+}
+
+func testGoto() bool {
+	source := `
+	int find(int target) {
+		int i;
+		int j;
+		for (i = 0; i < 5; i++) {
+			for (j = 0; j < 5; j++) {
+				if (i * j == target) {
+					goto found;
+				}
+			}
+		}
+		return -1;
+	found:
+		return i * 10 + j;
+	}
+
+	int cleanup(int fail) {
+		int status = 0;
+		if (fail) {
+			status = 1;
+			goto out;
+		}
+		status = 2;
+	out:
+		status = status + 10;
+		return status;
+	}
+
+	int main() {
+		if (find(6) != 23 || find(100) != -1) {
+			return 1;
+		}
+		if (cleanup(1) != 11 || cleanup(0) != 12) {
+			return 2;
+		}
+
+		int n = 0;
+	again:
+		n++;
+		if (n < 5) {
+			goto again;
+		}
+		if (n != 5) {
+			return 3;
+		}
+
+		int k = 0;
+		while (1) {
+			do {
+				k++;
+				if (k == 3) {
+					goto done;
+				}
+			} while (1);
+		}
+	done:
+		if (k != 3) {
+			return 4;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Goto", source) &&
+		runError("Undefined label", "int main() { goto missing; return 0; }", "label missing used but not defined") &&
+		runError("Duplicate label", "int main() { a: ; a: ; return 0; }", "duplicate label a")
+}
+
+func testGlobals() bool {
+	source := `
+	int counter = 10;
+	int zero;
+	double scale = 1.5;
+	int table[3] = {1, 2, 3};
+	char *name = "cint";
+	extern int later;
+
+	static int bump(int by) {
+		counter += by;
+		return counter;
+	}
+
+	int calls(void) {
+		static int n;
+		static int start = 100;
+		n++;
+		start++;
+		return n * 1000 + start;
+	}
+
+	int readLater(void) {
+		extern int later;
+		return later;
+	}
+
+	int later = 42;
+
+	int main() {
+		if (counter != 10 || zero != 0 || scale != 1.5 || table[2] != 3 || name[1] != 'i') {
+			return 1;
+		}
+		if (bump(5) != 15 || counter != 15) {
+			return 2;
+		}
+		calls();
+		if (calls() != 2102) {
+			return 3;
+		}
+		if (readLater() != 42) {
+			return 4;
+		}
+
+		int later = 0;
+		{
+			extern int later;
+			if (later != 42) {
+				return 5;
+			}
+		}
+
+		register int r = 3;
+		auto int a = 4;
+		if (r + a != 7) {
+			return 6;
+		}
+		return 0;
+	}
+	`
+	interp, err := cint.New(source)
+	if err == nil {
+		err = stepChecks(interp)
+	}
+	if err == nil {
+		// Globals and statics start over from their initial values
+		interp.Reset()
+		err = stepChecks(interp)
+	}
+	if err != nil {
+		fmt.Printf("❌ Globals test failed: %v\n", err)
+		return false
+	}
+	fmt.Println("✅ Globals test passed")
+
+	return runError("Statement at file scope", "int x; x = 3; int main() { return x; }", "expected declaration") &&
+		runError("Auto at file scope", "auto int x; int main() { return 0; }", "file-scope declaration specifies auto")
+}
+
+func testLvalues() bool {
+	source := `
+	int total = 0;
+	struct pair { int a; int b[2]; };
+
+	void add(int n) {
+		total += n;
+	}
+
+	int main() {
+		int sum = 0;
+		int i;
+		for (i = 1; i <= 4; i++) {
+			sum = sum + i;
+		}
+		if (sum != 10) {
+			return 1;
+		}
+
+		add(3);
+		add(4);
+		if (total != 7) {
+			return 2;
+		}
+
+		int x = 5;
+		while (x < 8) {
+			x++;
+		}
+		{
+			x *= 2;
+			x -= 1;
+			--x;
+		}
+		if (x != 14) {
+			return 3;
+		}
+
+		int a[3] = {0, 0, 0};
+		int *p = &a[1];
+		*p = 4;
+		p[1] += 2;
+		a[0]++;
+		if (a[0] != 1 || a[1] != 4 || a[2] != 2) {
+			return 4;
+		}
+
+		struct pair s;
+		struct pair *ps = &s;
+		s.a = 1;
+		ps->a += 5;
+		ps->b[1] = 9;
+		s.b[0] = s.b[1]--;
+		if (s.a != 6 || s.b[0] != 9 || s.b[1] != 8) {
+			return 5;
+		}
+
+		int y;
+		int z;
+		y = z = 3;
+		if (y != 3 || z != 3) {
+			return 6;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Lvalues", source)
+}
+
+func testShortCircuit() bool {
+	source := `
+	int calls = 0;
+
+	int touch(int v) {
+		calls++;
+		return v;
+	}
+
+	int main() {
+		int *p = 0;
+		if (p != 0 && *p) {
+			return 1;
+		}
+		if (p == 0 || *p) {
+		} else {
+			return 2;
+		}
+
+		if (touch(0) && touch(1)) {
+			return 3;
+		}
+		if (touch(1) || touch(1)) {
+		}
+		if (calls != 2) {
+			return 4;
+		}
+		if ((touch(1) && touch(2)) != 1 || (touch(0) || touch(0)) != 0) {
+			return 5;
+		}
+		if (calls != 6) {
+			return 6;
+		}
+
+		int a[3] = {1, 2, 0};
+		int n = 0;
+		while (n < 3 && a[n]) {
+			n++;
+		}
+		if (n != 2) {
+			return 7;
+		}
+
+		int i;
+		int j;
+		int sum = 0;
+		for (i = 0, j = 10; i < j; i++, j--) {
+			sum++;
+		}
+		if (sum != 5) {
+			return 8;
+		}
+		int k = (i = 1, j = 2, i + j);
+		if (k != 3) {
+			return 9;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Short-circuit and comma", source)
+}
+
+func testSizedIntegers() bool {
+	source := `
+	unsigned hash(char *s) {
+		unsigned h = 2166136261u;
+		while (*s) {
+			h = (h ^ (unsigned char)*s++) * 16777619u;
+		}
+		return h;
+	}
+
+	int main() {
+		char c = 127;
+		c++;
+		if (c != -128) {
+			return 1;
+		}
+		unsigned char uc = 250;
+		uc += 10;
+		if (uc != 4) {
+			return 2;
+		}
+		short s = 32767;
+		s = s + 1;
+		if (s != -32768) {
+			return 3;
+		}
+		unsigned u = 0;
+		u--;
+		if (u != 4294967295u || u + 1 != 0) {
+			return 4;
+		}
+		int i = 2147483647;
+		long l = i + 1L;
+		if (l != 2147483648L) {
+			return 5;
+		}
+
+		if (-8 >> 1 != -4 || 0x80000000u >> 31 != 1 || (unsigned)-8 >> 28 != 15) {
+			return 6;
+		}
+		if (-1 < 0u) {
+			return 7;
+		}
+		if (uc + 1 != 5 || sizeof(uc + 1) != 4) {
+			return 8;
+		}
+		if (hash("abc") != 440920331u) {
+			return 9;
+		}
+		return 0;
+	}
+	`
+	printed := `
+	int main() {
+		double zero = 0.0;
+		double inf = 1.0 / zero;
+		printf("%f %f %F %E|%6.2f|%-5e|%+g|%d %u %x %hhd\n", inf, -inf, inf, -inf, inf, inf, inf, -1, -1, 255u, 300);
+		return 0;
+	}
+	`
+	return runChecks("Sized integers", source) &&
+		runOutput("Printf", printed, "inf -inf INF -INF|   inf|inf  |+inf|-1 4294967295 ff 44\n")
+}
+
+func testFloats() bool {
+	source := `
+	double half(double x) {
+		return x / 2;
+	}
+
+	int truncate(double x) {
+		return x;
+	}
+
+	int main() {
+		float f = 0.1;
+		double d = 0.1;
+		if (f == d || (double)f != 0.100000001490116119384765625) {
+			return 1;
+		}
+		if (7 / 2 != 3 || 7 / 2.0 != 3.5 || 7 % 2 != 1) {
+			return 2;
+		}
+		int i = 2.9;
+		if (i != 2 || half(3) != 1.5 || truncate(-2.7) != -2) {
+			return 3;
+		}
+		double x = 1;
+		x += 0.5;
+		x *= 3;
+		x -= 0.5;
+		x /= 2;
+		x++;
+		if (x != 3) {
+			return 4;
+		}
+		float g = 16777216;
+		g++;
+		if (g != 16777216) {
+			return 5;
+		}
+		if (sizeof(f) != 4 || sizeof(d) != 8 || sizeof(1.0f) != 4) {
+			return 6;
+		}
+		return 0;
+	}
+	`
+	if !runChecks("Floats", source) {
+		return false
+	}
+
+	// main's value is converted to its return type like any other function's
+	interp, err := cint.New("int main() { return 4.5; }")
+	if err != nil {
+		fmt.Printf("❌ Main return test failed: %v\n", err)
+		return false
+	}
+	interp.EnableSingleStep()
+	result := interp.Step()
+	if result.Error != nil || result.ReturnVal == nil || result.ReturnVal.Type != "int" || result.ReturnVal.Int != 4 {
+		fmt.Printf("❌ Main return test failed: got %+v\n", result)
+		return false
+	}
+	fmt.Println("✅ Main return test passed")
+	return true
+}
+
+func testCasts() bool {
+	source := `
+	typedef unsigned char byte;
+
+	int main() {
+		int sum = 7;
+		int n = 2;
+		if ((double)sum / n != 3.5 || sum / n != 3) {
+			return 1;
+		}
+		char c = 'a';
+		if ((char)(c + 1) != 'b' || (int)3.99 != 3 || (int)-3.99 != -3) {
+			return 2;
+		}
+		if ((byte)300 != 44 || (signed char)200 != -56 || (unsigned short)-1 != 65535) {
+			return 3;
+		}
+		if ((unsigned)-1 != 4294967295u || (long)(unsigned)-1 != 4294967295L) {
+			return 4;
+		}
+
+		int words[2] = {0x01020304, 0};
+		char *bytes = (char *)words;
+		if (bytes[0] != 4 || bytes[3] != 1) {
+			return 5;
+		}
+		int *back = (int *)bytes;
+		if (*back != 0x01020304) {
+			return 6;
+		}
+		(void)sum;
+		double d = (float)0.1;
+		if (d == 0.1) {
+			return 7;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Casts", source) &&
+		runError("Cast with name", "int main() { return (int x)1; }", "in type name")
+}
+
+func testSizeof() bool {
+	source := `
+	struct mixed { char c; int i; char d; };
+	struct packed { char a; char b; };
+	union u { char c; double d; };
+
+	int main() {
+		int a[10];
+		int m[3][4];
+		char *p = 0;
+		if (sizeof(char) != 1 || sizeof(short) != 2 || sizeof(int) != 4 || sizeof(long) != 8) {
+			return 1;
+		}
+		if (sizeof(float) != 4 || sizeof(double) != 8 || sizeof(char *) != 8 || sizeof p != 8) {
+			return 2;
+		}
+		if (sizeof(a) != 40 || sizeof(a) / sizeof(a[0]) != 10 || sizeof m != 48 || sizeof m[0] != 16) {
+			return 3;
+		}
+		if (sizeof(struct mixed) != 12 || sizeof(struct packed) != 2 || sizeof(union u) != 8) {
+			return 4;
+		}
+		int n = 0;
+		if (sizeof(n++) != 4 || n != 0) {
+			return 5;
+		}
+		if (sizeof "abc" != 4 || sizeof(int[5]) != 20 || sizeof(int (*)[3]) != 8) {
+			return 6;
+		}
+		int b[sizeof a / sizeof a[0]];
+		if (sizeof b != 40) {
+			return 7;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Sizeof", source)
+}
+
+func testHeap() bool {
+	source := `
+	int main() {
+		int n = 5;
+		int *v = malloc(n * sizeof(int));
+		int i;
+		for (i = 0; i < n; i++) {
+			v[i] = i * i;
+		}
+		v = realloc(v, 10 * sizeof(int));
+		if (v[4] != 16) {
+			return 1;
+		}
+		free(v);
+
+		int *z = calloc(4, sizeof(int));
+		if (z[0] != 0 || z[3] != 0) {
+			return 2;
+		}
+		free(z);
+
+		for (i = 0; i < 70000; i++) {
+			char *p = malloc(1024);
+			p[1023] = 1;
+			free(p);
+		}
+
+		char *a = malloc(10);
+		char *b = malloc(40);
+		char *c = malloc(10);
+		b[35] = 7;
+		free(b);
+		char *d = malloc(20);
+		char *e = malloc(16);
+		if (d != b || e != b + 32 || e[3] != 0) {
+			return 3;
+		}
+		free(a);
+		free(d);
+		free(e);
+		char *f = malloc(60);
+		if (f != a) {
+			return 4;
+		}
+		free(c);
+		free(f);
+		free(0);
+		return 0;
+	}
+	`
+	shadowed := `
+	int allocated = 0;
+
+	int malloc(int n) {
+		allocated += n;
+		return allocated;
+	}
+
+	int main() {
+		if (malloc(3) != 3 || malloc(4) != 7) {
+			return 1;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Heap", source) &&
+		runChecks("User-defined malloc", shadowed)
+}
+
+func testDeclarators() bool {
+	source := `
+	struct point { int x; int y; };
+	struct line { struct point from; struct point to; char name[8]; };
+
+	int main() {
+		int i, j, k = 3;
+		char *p, c = 'z';
+		int *q, r[2] = {4, 5}, **pp = &q;
+		i = 1;
+		j = 2;
+		p = &c;
+		q = r;
+		if (i + j + k != 6 || *p != 'z' || sizeof c != 1 || **pp != 4) {
+			return 1;
+		}
+
+		int m[2][3] = {{1, 2, 3}, {4, 5, 6}};
+		int flat[2][2] = {1, 2, 3};
+		int partial[4] = {7,};
+		if (m[1][2] != 6 || flat[1][0] != 3 || flat[1][1] != 0 || partial[0] != 7 || partial[3] != 0) {
+			return 2;
+		}
+
+		struct line l = {{1, 2}, {3, 4}, "diag"};
+		struct line loose = {5, 6, 7};
+		if (l.to.x != 3 || l.name[3] != 'g' || l.name[4] != 0 || loose.to.x != 7 || loose.to.y != 0) {
+			return 3;
+		}
+
+		struct point pts[] = {{1, 2}, {3, 4}, 5, 6};
+		if (sizeof pts / sizeof pts[0] != 3 || pts[2].y != 6) {
+			return 4;
+		}
+		char s[] = "hi", t[5] = "yo";
+		if (sizeof s != 3 || t[1] != 'o' || t[4] != 0) {
+			return 5;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Declarators", source) &&
+		runError("Too many initializers", "int main() { int a[2] = {1, 2, 3}; return 0; }", "too many initializers")
+}
+
+func testKandR() bool {
+	source := `
+	max(a, b)
+	int a, b;
+	{
+		return a > b ? a : b;
+	}
+
+	double scale(x, factor)
+	double x;
+	{
+		return x * factor;
+	}
+
+	char first(s)
+	char *s;
+	{
+		return s[0];
+	}
+
+	main()
+	{
+		if (max(3, 9) != 9 || max(-1, -5) != -1) {
+			return 1;
+		}
+		if (scale(1.5, 2) != 3) {
+			return 2;
+		}
+		if (first("kr") != 'k') {
+			return 3;
+		}
+		if (later(4) != 8) {
+			return 4;
+		}
+		return 0;
+	}
+
+	int later(n)
+	int n;
+	{
+		return n * 2;
+	}
+	`
+	return runChecks("K&R definitions", source) &&
+		runError("Undeclared parameter", "int f(a) int b; { return 0; } int main() { return 0; }", "no such parameter")
+}
+
+func testPrototypes() bool {
+	source := `
+	double average(int a, int b);
+	char low(char c);
+	int count(void);
+	int sum(int n, ...);
+	int legacy();
+
+	int main() {
+		if (average(3, 4) != 3.5 || average(2.9, 1) != 1.5) {
+			return 1;
+		}
+		if (low(321) != 65 || count() != 0) {
+			return 2;
+		}
+		if (sum(2, 10, 20) != 2 || legacy(1, 2, 3) != 1) {
+			return 3;
+		}
+		return 0;
+	}
+
+	double average(int a, int b) {
+		return (a + b) / 2.0;
+	}
+
+	char low(char c) {
+		return c;
+	}
+
+	int count(void) {
+		return 0;
+	}
+
+	int sum(int n, ...) {
+		return n;
+	}
+
+	int legacy(a)
+	int a;
+	{
+		return a;
+	}
+	`
+	return runChecks("Prototypes", source) &&
+		runError("Too few arguments", "int f(int a, int b); int main() { return f(1); } int f(int a, int b) { return a; }", "too few arguments to function f") &&
+		runError("Too many arguments", "int f(int a); int main() { return f(1, 2); } int f(int a) { return a; }", "too many arguments to function f") &&
+		runError("Conflicting prototype", "int f(int a); double f(int a) { return a; } int main() { return 0; }", "conflicting types for function f")
+}
+
+func testLiterals() bool {
+	source := `
+	#define str(x) #x
+
+	int main() {
+		if (0xFF != 255 || 0755 != 493 || 0 != 00 || 0x7fffffffffffffff != 9223372036854775807L) {
+			return 1;
+		}
+		if (sizeof 1 != 4 || sizeof 1L != 8 || sizeof 1u != 4 || sizeof 1.0f != 4 || sizeof 1.0 != 8) {
+			return 2;
+		}
+		if (-1 < 0u || 4294967295 < 0 || sizeof 4294967295 != 8) {
+			return 3;
+		}
+		if ('\n' != 10 || '\x41' != 'A' || '\101' != 'A' || '\0' != 0 || '\\' != 92 || '\'' != 39) {
+			return 4;
+		}
+		char *s = "a\tb\x41\101\0z";
+		if (s[1] != 9 || s[3] != 'A' || s[4] != 'A' || s[5] != 0 || s[6] != 'z') {
+			return 5;
+		}
+		char joined[] = "con" "cat"
+			"enated";
+		if (sizeof joined != 13 || joined[3] != 'c' || joined[6] != 'e') {
+			return 6;
+		}
+		if (1.5e3 != 1500 || .5 != 0.5 || 2. != 2) {
+			return 7;
+		}
+
+		char *raw = str("abc\0d");
+		char *ch = str('\4');
+		if (sizeof str("abc\0d") != 9 || raw[4] != '\\' || raw[5] != '0' || ch[2] != '4') {
+			return 8;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Literals", source)
+}
+
+func testStrings() bool {
+	source := `
+	int length(char *s) {
+		int n = 0;
+		while (*s++) {
+			n++;
+		}
+		return n;
+	}
+
+	void copy(char *dst, char *src) {
+		while (*dst++ = *src++) {
+		}
+	}
+
+	int compare(char *a, char *b) {
+		while (*a && *a == *b) {
+			a++;
+			b++;
+		}
+		return *a - *b;
+	}
+
+	int main() {
+		char s[] = "hi";
+		s[0] = 'H';
+		if (s[0] != 'H' || s[2] != 0 || sizeof s != 3) {
+			return 1;
+		}
+		char *p = "abc";
+		p++;
+		if (*p != 'b' || length(p) != 2 || length("") != 0) {
+			return 2;
+		}
+		char buf[16];
+		copy(buf, "hello");
+		if (length(buf) != 5 || compare(buf, "hello") != 0 || compare("abc", "abd") >= 0) {
+			return 3;
+		}
+		char *same = "abc";
+		if ("abc"[2] != 'c' || same[3] != 0) {
+			return 4;
+		}
+		return 0;
+	}
+	`
+	printed := `
+	int main() {
+		char word[6] = "world";
+		char *p = word;
+		p[0] = 'W';
+		printf("%s, %s! %.3s|%5s|%-4s|\n", "Hello", p, "abcdef", "ab", "cd");
+		return 0;
+	}
+	`
+	return runChecks("Strings", source) &&
+		runOutput("Printf strings", printed, "Hello, World! abc|   ab|cd  |\n")
+}
+
+func testMacros() bool {
+	source := `
+	#define MAX 100
+	#define SQUARE(x) ((x) * (x))
+	#define MIN(a, b) ((a) < (b) ? (a) : (b))
+	#define str(x) #x
+	#define xstr(x) str(x)
+	#define cat(a, b) a ## b
+	#define xcat(a, b) cat(a, b)
+	#define f(x) g(x)
+	#define g(x) (x * 2)
+	#define EMPTY
+	#define LIST(...) sum(__VA_ARGS__)
+
+	int sum(int a, int b, int c) {
+		return a + b + c;
+	}
+
+	int main() {
+		int foo = 1;
+		int var12 = 7;
+	#define foo foo + 1
+		if (MAX != 100 || SQUARE(1 + 2) != 9 || MIN(MAX, 5) != 5) {
+			return 1;
+		}
+		char *s = str(a  +   b);
+		char *t = xstr(MAX);
+		char *u = str(MAX);
+		if (s[1] != ' ' || s[2] != '+' || sizeof str(a  +   b) != 6 || t[0] != '1' || u[0] != 'M') {
+			return 2;
+		}
+		if (cat(var, 12) != 7 || xcat(MA, X) != 100 || cat(1, 2) != 12) {
+			return 3;
+		}
+		if (foo != 2 || f(f(3)) != 12 EMPTY) {
+			return 4;
+		}
+		if (LIST(1, 2, 3) != 6) {
+			return 5;
+		}
+	#undef MAX
+	#define MAX 5
+		if (MAX != 5) {
+			return 6;
+		}
+		return 0;
+	}
+	`
+	badLine := "#define JUMP goto nowhere\nint main() {\n\tJUMP;\n}\n"
+	return runChecks("Macros", source) &&
+		runError("Macro arguments", "#define F(a, b) a\nint main() { return F(1); }", "macro F requires 2 arguments") &&
+		runError("Macro error line", badLine, "line 3")
+}
+
+func testInclude() bool {
+	files := fstest.MapFS{
+		"util.h": {Data: []byte(`
+			#pragma once
+			#include "lib/limits.h"
+			int twice(int x) { return x * 2; }
+		`)},
+		"lib/limits.h": {Data: []byte(`
+			#ifndef LIMITS_H
+			#define LIMITS_H
+			#define LIMIT 10
+			#endif
+		`)},
+		"sys/config.h": {Data: []byte(`#define CONFIG 3`)},
+		"broken.h":     {Data: []byte("int ok;\n#error broken header\n")},
+		"bad.h":        {Data: []byte("int ok;\nint f() { goto missing; }\n")},
+	}
+	source := `
+	#include "util.h"
+	#include "util.h"
+	#include "lib/limits.h"
+	#include <config.h>
+	#include <stdio.h>
+	#include <stdlib.h>
+	#define HEADER "util.h"
+	#include HEADER
+
+	int main() {
+		if (twice(LIMIT) != 20 || CONFIG != 3) {
+			return 1;
+		}
+		int *p = malloc(sizeof(int));
+		*p = 1;
+		free(p);
+		return 0;
+	}
+	`
+	dirs := cint.WithIncludeDirs("sys")
+	return runChecks("Include", source, cint.WithFS(files), dirs) &&
+		runError("Missing include", "#include \"none.h\"\nint main() { return 0; }", "cannot include none.h: no such file at line 1", cint.WithFS(files)) &&
+		runError("Bracketed include outside include dirs", "#include <util.h>\nint main() { return 0; }", "cannot include util.h", cint.WithFS(files), dirs) &&
+		runError("Preprocessor error in header", "#include \"broken.h\"\nint main() { return 0; }", "#error broken header at broken.h:2", cint.WithFS(files)) &&
+		runError("Parse error in header", "#include \"bad.h\"\nint main() { return 0; }", "label missing used but not defined at bad.h:2", cint.WithFS(files))
+}
+
+func testPreprocessorConditionals() bool {
+	source := `
+	#define VERSION 3
+	#define HAVE(x) defined(HAVE_ ## x)
+	#define HAVE_FLOAT 1
+	#define USES_FLOAT defined(HAVE_FLOAT)
+
+	#if VERSION >= 3 && VERSION * 2 == 6 && (VERSION << 2) == 12 && 7 / 2 == 3 && 7 % 4 == 3
+	int arith = 1;
+	#else
+	int arith = 0;
+	#endif
+
+	#if -1 < 0u || 0x10 != 16 || '\n' != 10 || (1 ? 2 : 3) != 2 || UNDEFINED != 0
+	int unsignedCompare = 0;
+	#else
+	int unsignedCompare = 1;
+	#endif
+
+	#if HAVE(FLOAT) && USES_FLOAT && !HAVE(DOUBLE) && defined VERSION
+	int fromMacro = 1;
+	#else
+	int fromMacro = 0;
+	#endif
+
+	#ifdef DEBUG
+	int debug = DEBUG;
+	#elif defined(LEVEL) && LEVEL > 1
+	int debug = 100;
+	#else
+	int debug = -1;
+	#endif
+
+	#if 0
+	This doesn't work yet, and "this never ends
+	#if 1
+	#error not reached
+	#endif
+	/* #endif
+	*/
+	#elif 1
+	int skipped = 1;
+	#elif don't care
+	#else
+	int skipped = 2;
+	#endif
+
+	int main() {
+		if (arith != 1 || unsignedCompare != 1 || fromMacro != 1 || skipped != 1) {
+			return 1;
+		}
+		if (debug != EXPECTED) {
+			return 2;
+		}
+		return 0;
+	}
+	`
+	return runChecks("Preprocessor conditionals", source, cint.WithDefine("EXPECTED", "-1")) &&
+		runChecks("Preprocessor conditionals with DEBUG", source, cint.WithDefine("DEBUG", "7"), cint.WithDefine("EXPECTED", "7")) &&
+		runChecks("Preprocessor conditionals with LEVEL", source, cint.WithDefine("LEVEL", "2"), cint.WithDefine("EXPECTED", "100")) &&
+		runError("Unterminated #if", "#if 1\nint main() { return 0; }\n", "unterminated #if at line 1") &&
+		runError("Division by zero in #if", "#if 1 / 0\n#endif\nint main() { return 0; }\n", "division by zero")
+}
+
 func main() {
 	fmt.Println("=== C Interpreter Test Suite ===\n")
 
@@ -226,6 +1844,34 @@ func main() {
 		{"Loops", testLoops},
 		{"Conditionals", testConditionals},
 		{"Operators", testOperators},
+		{"Scoping", testScoping},
+		{"Unsigned Conversions", testUnsignedConversions},
+		{"Goto Into Block", testGotoIntoBlock},
+		{"Arrays", testArrays},
+		{"Pointers", testPointers},
+		{"Pointer Arithmetic", testPointerArithmetic},
+		{"Structs and Unions", testStructs},
+		{"Enums", testEnums},
+		{"Typedefs", testTypedefs},
+		{"Switch", testSwitch},
+		{"Do-While", testDoWhile},
+		{"Goto", testGoto},
+		{"Globals", testGlobals},
+		{"Lvalues", testLvalues},
+		{"Short-Circuit and Comma", testShortCircuit},
+		{"Sized Integers", testSizedIntegers},
+		{"Floats", testFloats},
+		{"Casts", testCasts},
+		{"Sizeof", testSizeof},
+		{"Heap", testHeap},
+		{"Declarators", testDeclarators},
+		{"K&R Definitions", testKandR},
+		{"Prototypes", testPrototypes},
+		{"Literals", testLiterals},
+		{"Strings", testStrings},
+		{"Macros", testMacros},
+		{"Include", testInclude},
+		{"Preprocessor Conditionals", testPreprocessorConditionals},
 	}
 
 	passed := 0
@@ -0,0 +1,206 @@
+package cint
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// formatPrintf formats args according to a C printf format string and returns the
+// resulting text. Each conversion specification has the form
+// %[flags][width][.precision][length]conversion, where the flags are any of "-+ #0",
+// the width and precision may be given as '*' to take them from the argument list,
+// and the length modifier is one of hh, h, l, ll, z, j, t or L. The length modifier
+// selects the width that an integer argument is converted to before printing, so
+// %hhu prints the low byte of its argument and %lu prints all 64 bits as unsigned.
+// Supported conversions are d, i, u, o, x, X, c, s, f, F, e, E, g, G, p and %.
+// Returns an error if there are too few arguments or a specification is malformed.
+func (i *Interpreter) formatPrintf(format string, args []*Value) (string, error) {
+	var out strings.Builder
+	next := func() (*Value, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("printf: too few arguments for format %q", format)
+		}
+		arg := args[0]
+		args = args[1:]
+		return arg, nil
+	}
+
+	for pos := 0; pos < len(format); pos++ {
+		ch := format[pos]
+		if ch != '%' {
+			out.WriteByte(ch)
+			continue
+		}
+
+		// Flags
+		spec := "%"
+		pos++
+		for pos < len(format) && strings.IndexByte("-+ #0", format[pos]) >= 0 {
+			spec += string(format[pos])
+			pos++
+		}
+
+		// Width and precision
+		for _, part := range []string{"", "."} {
+			if part == "." {
+				if pos >= len(format) || format[pos] != '.' {
+					break
+				}
+				spec += "."
+				pos++
+			}
+			if pos < len(format) && format[pos] == '*' {
+				arg, err := next()
+				if err != nil {
+					return "", err
+				}
+				spec += fmt.Sprint(int32(arg.Int))
+				pos++
+				continue
+			}
+			for pos < len(format) && format[pos] >= '0' && format[pos] <= '9' {
+				spec += string(format[pos])
+				pos++
+			}
+		}
+
+		// Length modifier
+		length := ""
+		for pos < len(format) && strings.IndexByte("hljztL", format[pos]) >= 0 {
+			length += string(format[pos])
+			pos++
+		}
+		if pos >= len(format) {
+			return "", fmt.Errorf("printf: incomplete conversion specification in %q", format)
+		}
+
+		conv := format[pos]
+		if conv == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		arg, err := next()
+		if err != nil {
+			return "", err
+		}
+
+		switch conv {
+		case 'd', 'i':
+			n := arg.Int
+			if isFloatType(arg.Type) {
+				n = int64(arg.Float)
+			}
+			fmt.Fprintf(&out, spec+"d", convertInt(n, lengthType(length, false)))
+		case 'u', 'o', 'x', 'X':
+			n := arg.Int
+			if isFloatType(arg.Type) {
+				n = int64(arg.Float)
+			}
+			verb := string(conv)
+			if conv == 'u' {
+				verb = "d"
+			}
+			fmt.Fprintf(&out, spec+verb, uint64(convertInt(n, lengthType(length, true))))
+		case 'c':
+			fmt.Fprintf(&out, spec+"s", string([]byte{byte(arg.Int)}))
+		case 's':
+			str, err := i.stringArg(arg)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&out, spec+"s", str)
+		case 'f', 'F', 'e', 'E', 'g', 'G':
+			f := floatValue(arg)
+			if math.IsInf(f, 0) || math.IsNaN(f) {
+				fmt.Fprintf(&out, nonFiniteSpec(spec)+"s", nonFiniteText(spec, conv, f))
+				continue
+			}
+			// C prints six digits of precision unless told otherwise
+			if !strings.Contains(spec, ".") {
+				spec += ".6"
+			}
+			verb := string(conv)
+			if conv == 'F' {
+				verb = "f"
+			}
+			fmt.Fprintf(&out, spec+verb, f)
+		case 'p':
+			fmt.Fprintf(&out, spec+"s", fmt.Sprintf("0x%x", uint64(arg.Int)))
+		default:
+			return "", fmt.Errorf("printf: unknown conversion %%%c", conv)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// nonFiniteText returns how C prints the infinity or NaN f for the floating
+// conversion conv: "inf" or "nan", in upper case for F, E and G, with a sign
+// when f is negative or the '+' or ' ' flag in spec asks for one.
+func nonFiniteText(spec string, conv byte, f float64) string {
+	text := "inf"
+	if math.IsNaN(f) {
+		text = "nan"
+	}
+	if conv == 'F' || conv == 'E' || conv == 'G' {
+		text = strings.ToUpper(text)
+	}
+
+	flags := spec[1:]
+	flags = flags[:len(flags)-len(strings.TrimLeft(flags, "-+ #0"))]
+	switch {
+	case math.Signbit(f):
+		return "-" + text
+	case strings.Contains(flags, "+"):
+		return "+" + text
+	case strings.Contains(flags, " "):
+		return " " + text
+	}
+	return text
+}
+
+// nonFiniteSpec returns the part of spec that applies to printing an infinity
+// or NaN: the field width and left justification. Precision, zero padding and
+// the other flags have no effect on them.
+func nonFiniteSpec(spec string) string {
+	width := strings.TrimLeft(spec[1:], "-+ #0")
+	flags := spec[1 : len(spec)-len(width)]
+	width, _, _ = strings.Cut(width, ".")
+	if strings.Contains(flags, "-") && !strings.HasPrefix(width, "-") {
+		width = "-" + width
+	}
+	return "%" + width
+}
+
+// lengthType returns the integer type that a printf length modifier converts its
+// argument to, signed or unsigned as requested. Without a modifier the argument is
+// printed as an int.
+func lengthType(length string, unsigned bool) string {
+	typ := "int"
+	switch length {
+	case "hh":
+		typ = "char"
+	case "h":
+		typ = "short"
+	case "l", "ll", "z", "j", "t", "L":
+		typ = "long"
+	}
+	if unsigned {
+		return "unsigned " + typ
+	}
+	return typ
+}
+
+// stringArg returns the text of a %s argument, which is the NUL-terminated chars
+// its pointer refers to in interpreter memory. A null pointer prints as "(null)".
+func (i *Interpreter) stringArg(arg *Value) (string, error) {
+	if !isPointerType(arg.Type) {
+		return "", fmt.Errorf("printf: %%s expects a char pointer, not %s", arg.Type)
+	}
+	if arg.Int == 0 {
+		return "(null)", nil
+	}
+	return i.memory.cString(arg.Int)
+}
@@ -0,0 +1,17 @@
+/* math.h - the mathematical functions built into cint */
+
+#ifndef _MATH_H
+#define _MATH_H
+
+double sqrt(double x);
+double pow(double x, double y);
+double sin(double x);
+double cos(double x);
+double tan(double x);
+double floor(double x);
+double ceil(double x);
+double log(double x);
+double log10(double x);
+double exp(double x);
+
+#endif
@@ -0,0 +1,11 @@
+/* stddef.h - common definitions */
+
+#ifndef _STDDEF_H
+#define _STDDEF_H
+
+typedef unsigned long size_t;
+typedef long ptrdiff_t;
+
+#define NULL ((void *)0)
+
+#endif
@@ -0,0 +1,14 @@
+/* stdio.h - the input and output functions built into cint */
+
+#ifndef _STDIO_H
+#define _STDIO_H
+
+typedef unsigned long size_t;
+
+#define NULL ((void *)0)
+#define EOF (-1)
+
+int printf(char *format, ...);
+int putchar(int c);
+
+#endif
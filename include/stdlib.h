@@ -0,0 +1,18 @@
+/* stdlib.h - the general utility functions built into cint */
+
+#ifndef _STDLIB_H
+#define _STDLIB_H
+
+typedef unsigned long size_t;
+
+#define NULL ((void *)0)
+#define EXIT_SUCCESS 0
+#define EXIT_FAILURE 1
+
+void *malloc(size_t size);
+void *calloc(size_t count, size_t size);
+void *realloc(void *ptr, size_t size);
+void free(void *ptr);
+int abs(int n);
+
+#endif
@@ -0,0 +1,8 @@
+/* unistd.h - sleep, which in cint pauses for a number of milliseconds */
+
+#ifndef _UNISTD_H
+#define _UNISTD_H
+
+int sleep(int milliseconds);
+
+#endif
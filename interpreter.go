@@ -3,33 +3,42 @@ package cint
 import (
 	"fmt"
 	"math"
+	"strings"
 	"time"
 )
 
 
 // Value represents a dynamically-typed value used by the interpreter.
-// It can hold an integer, float, string, or a generic pointer, along with its type as a string.
+// It can hold an integer, float, or a generic pointer, along with its type as a string.
+// Pointer values hold the address they refer to in Int; a string is a pointer to the
+// NUL-terminated chars it holds in interpreter memory.
 type Value struct {
 	Type  string
 	Int   int64
 	Float float64
-	Str   string
 	Ptr   interface{}
 }
 
+// Variable describes a named object in interpreter memory: its declared type
+// and the address of its storage.
+type Variable struct {
+	Type string
+	Addr int64
+}
+
 
 // Environment represents a variable scope with its own symbol table (store) and an optional
 // reference to an outer (enclosing) environment. This structure enables lexical scoping
 // and supports nested environments, such as those created by function calls or blocks.
 type Environment struct {
-	store map[string]*Value
+	store map[string]*Variable
 	outer *Environment
 }
 
 // NewEnvironment creates and returns a new Environment instance with an empty store.
 // The returned Environment has no outer (parent) environment set.
 func NewEnvironment() *Environment {
-	s := make(map[string]*Value)
+	s := make(map[string]*Variable)
 	return &Environment{store: s, outer: nil}
 }
 
@@ -49,10 +58,10 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
-// Get retrieves the Value associated with the given name from the current environment.
+// Get retrieves the Variable associated with the given name from the current environment.
 // If the name is not found in the current environment, it recursively searches in the outer environments.
-// Returns the Value and a boolean indicating whether the name was found.
-func (e *Environment) Get(name string) (*Value, bool) {
+// Returns the Variable and a boolean indicating whether the name was found.
+func (e *Environment) Get(name string) (*Variable, bool) {
 	val, ok := e.store[name]
 	if !ok && e.outer != nil {
 		val, ok = e.outer.Get(name)
@@ -61,11 +70,13 @@ func (e *Environment) Get(name string) (*Value, bool) {
 }
 
 
-// Set assigns the given Value to the specified name in the Environment's store.
-// If the name already exists, its value is overwritten. Returns the assigned Value.
-func (e *Environment) Set(name string, val *Value) *Value {
-	e.store[name] = val
-	return val
+// Set binds the given Variable to the specified name in the Environment's store.
+// If the name already exists in this scope, the binding is replaced. Returns the bound Variable.
+// Set declares a name in this scope only; assignments never rebind names but write through
+// the variable's address, so they reach the original storage whichever scope it belongs to.
+func (e *Environment) Set(name string, v *Variable) *Variable {
+	e.store[name] = v
+	return v
 }
 
 // StepResult represents the outcome of executing a single step in the interpreter.
@@ -92,23 +103,39 @@ type Interpreter struct {
 	program    *Program
 	globals    *Environment
 	functions  map[string]*FunctionDecl
+	structs    map[string]*StructDecl // struct and union definitions by type name
+	layouts    map[string]*layout
 	builtins   map[string]func([]Expression, *Environment) (*Value, error)
 	stepMode   bool
 	stepIndex  int
 	stepStack  []Statement // Stack of statements to execute
 	currentEnv *Environment
+	memory     *Memory
+	strings    map[string]int64 // addresses of strings copied into memory
+	globalsSet bool             // whether global variables have been initialized
+
+	// statics holds the static variables declared inside functions, once allocated
+	statics map[*VarDecl]*Variable
 
 	// Control flow
 	shouldReturn   bool
 	returnValue    *Value
 	shouldBreak    bool
 	shouldContinue bool
+
+	// jumpTarget is the label of a goto being carried out, or the case label a switch
+	// starts at. Until seeking is set a goto unwinds out of the statements that
+	// contain it, like a break. Once a statement list holding the label is reached,
+	// seeking is set and execution descends to the label, skipping every statement
+	// that does not contain it.
+	jumpTarget Statement
+	seeking    bool
 }
 
 
 // NewInterpreter creates and initializes a new Interpreter instance for the given Program.
 // It sets up the global environment, registers built-in functions, and collects all function
-// declarations from the program for later use.
+// declarations and struct and union definitions from the program for later use.
 //
 // Parameters:
 //   - program: The Program to be interpreted.
@@ -120,23 +147,44 @@ func NewInterpreter(program *Program) *Interpreter {
 		program:   program,
 		globals:   NewEnvironment(),
 		functions: make(map[string]*FunctionDecl),
+		structs:   make(map[string]*StructDecl),
+		layouts:   make(map[string]*layout),
 		builtins:  make(map[string]func([]Expression, *Environment) (*Value, error)),
 		stepStack: []Statement{},
+		memory:    NewMemory(),
+		strings:   make(map[string]int64),
+		statics:   make(map[*VarDecl]*Variable),
 	}
 
 	// Register built-in functions
 	interp.registerBuiltins()
 
-	// Extract function declarations
+	// Extract function declarations and type definitions
 	for _, stmt := range program.Statements {
-		if fn, ok := stmt.(*FunctionDecl); ok {
-			interp.functions[fn.Name] = fn
-		}
+		interp.registerDecl(stmt)
 	}
 
 	return interp
 }
 
+// registerDecl records the function declarations and struct and union definitions
+// found in a top-level statement, including those grouped in a DeclarationList.
+// A function's definition takes precedence over any prototypes of it.
+func (i *Interpreter) registerDecl(stmt Statement) {
+	switch node := stmt.(type) {
+	case *FunctionDecl:
+		if prev, ok := i.functions[node.Name]; !ok || prev.Body == nil {
+			i.functions[node.Name] = node
+		}
+	case *StructDecl:
+		i.structs[node.TypeName()] = node
+	case *DeclarationList:
+		for _, decl := range node.Declarations {
+			i.registerDecl(decl)
+		}
+	}
+}
+
 
 // EnableSingleStep enables single-step execution mode in the interpreter.
 // When single-step mode is active, the interpreter executes one instruction at a time,
@@ -153,23 +201,64 @@ func (i *Interpreter) DisableSingleStep() {
 }
 
 
+// initGlobals evaluates the global variable declarations of the program into the
+// global environment, in source order, the first time it is called after the
+// interpreter is created or reset. Globals live in static memory, so those
+// without an initializer start out zeroed as in C.
+func (i *Interpreter) initGlobals() error {
+	if i.globalsSet {
+		return nil
+	}
+	i.globalsSet = true
+
+	for _, stmt := range i.program.Statements {
+		switch stmt.(type) {
+		case *VarDecl, *DeclarationList, *StructDecl:
+			if err := i.evalStatement(stmt, i.globals); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Run executes the "main" function of the interpreter if it exists.
-// It sets up a new environment enclosed within the global environment,
-// evaluates the body of the main function, and returns any error encountered.
-// If no "main" function is found, it returns an error indicating this.
+// It initializes the global variables, sets up a new environment enclosed within
+// the global environment, evaluates the body of the main function, and returns
+// any error encountered. If no "main" function is found, it returns an error
+// indicating this.
 func (i *Interpreter) Run() error {
+	if err := i.initGlobals(); err != nil {
+		return err
+	}
+
 	// Execute main function if it exists
 	if mainFn, ok := i.functions["main"]; ok {
 		i.currentEnv = NewEnclosedEnvironment(i.globals)
-		_, err := i.evalFunctionBody(mainFn.Body, i.currentEnv)
-		return err
+		result, err := i.evalFunctionBody(mainFn.Body, i.currentEnv)
+		if err != nil {
+			return err
+		}
+		i.returnValue = mainReturn(mainFn, result)
+		return nil
 	}
 	return fmt.Errorf("no main function found")
 }
 
 
+// mainReturn converts the value returned by main to main's return type, as the
+// value returned by any other function is converted.
+func mainReturn(mainFn *FunctionDecl, val *Value) *Value {
+	if val == nil || mainFn.ReturnType == "void" {
+		return val
+	}
+	return convert(val, mainFn.ReturnType)
+}
+
+
 // Step executes the next statement in single-step mode for the interpreter.
-// It initializes the step stack with the main function's statements on the first call.
+// It initializes the global variables and the step stack with the main function's
+// statements on the first call.
 // Returns a StepResult containing the executed statement, any error encountered,
 // and flags indicating if execution is done, if a return, break, or continue was triggered,
 // and the return value if applicable. If single-step mode is not enabled or no main function
@@ -181,6 +270,9 @@ func (i *Interpreter) Step() *StepResult {
 
 	// Initialize on first step
 	if i.stepIndex == 0 && len(i.stepStack) == 0 {
+		if err := i.initGlobals(); err != nil {
+			return &StepResult{Error: err, Done: true}
+		}
 		if mainFn, ok := i.functions["main"]; ok {
 			if mainFn.Body != nil {
 				i.currentEnv = NewEnclosedEnvironment(i.globals)
@@ -201,6 +293,21 @@ func (i *Interpreter) Step() *StepResult {
 
 	// Evaluate the statement
 	err := i.evalStatement(stmt, i.currentEnv)
+	if i.shouldReturn {
+		i.returnValue = mainReturn(i.functions["main"], i.returnValue)
+	}
+
+	// A goto that leaves the statement resumes at the top-level statement holding its label
+	if i.jumping() {
+		if target := findLabel(i.stepStack, i.jumpTarget); target >= 0 {
+			i.seeking = true
+			// Variables declared between the goto and its label are in scope there
+			for idx := i.stepIndex; idx < target && err == nil; idx++ {
+				err = i.declareSkipped(i.stepStack[idx], i.currentEnv)
+			}
+			i.stepIndex = target
+		}
+	}
 
 	result := &StepResult{
 		Statement: stmt,
@@ -218,21 +325,29 @@ func (i *Interpreter) Step() *StepResult {
 
 // Reset reinitializes the Interpreter to its default state, clearing the step stack,
 // resetting the step index, creating a new environment, and clearing any control flow
-// or return flags. This prepares the Interpreter for a fresh execution.
+// or return flags. Global variables are restored to their initial values when execution
+// starts again. This prepares the Interpreter for a fresh execution.
 func (i *Interpreter) Reset() {
 	i.stepIndex = 0
 	i.stepStack = []Statement{}
 	i.currentEnv = NewEnvironment()
+	i.globals = NewEnvironment()
+	i.globalsSet = false
+	i.memory = NewMemory()
+	i.strings = make(map[string]int64)
+	i.statics = make(map[*VarDecl]*Variable)
 	i.shouldReturn = false
 	i.returnValue = nil
 	i.shouldBreak = false
 	i.shouldContinue = false
+	i.jumpTarget = nil
+	i.seeking = false
 }
 
 // evalStatement evaluates a given Statement node within the provided Environment.
 // It dispatches the evaluation based on the concrete type of the Statement, handling
-// variable declarations, expressions, control flow statements (if, while, for, block),
-// and flow control (return, break, continue). The method updates interpreter state
+// variable declarations, expressions, control flow statements (if, while, do-while, for, block),
+// and flow control (return, break, continue, goto). The method updates interpreter state
 // flags (shouldReturn, shouldBreak, shouldContinue) as needed and returns any error
 // encountered during evaluation.
 func (i *Interpreter) evalStatement(stmt Statement, env *Environment) error {
@@ -256,8 +371,12 @@ func (i *Interpreter) evalStatement(stmt Statement, env *Environment) error {
 		return i.evalIfStatement(node, env)
 	case *WhileStatement:
 		return i.evalWhileStatement(node, env)
+	case *DoWhileStatement:
+		return i.evalDoWhileStatement(node, env)
 	case *ForStatement:
 		return i.evalForStatement(node, env)
+	case *SwitchStatement:
+		return i.evalSwitchStatement(node, env)
 	case *BlockStatement:
 		return i.evalBlockStatement(node, env)
 	case *BreakStatement:
@@ -266,42 +385,339 @@ func (i *Interpreter) evalStatement(stmt Statement, env *Environment) error {
 	case *ContinueStatement:
 		i.shouldContinue = true
 		return nil
+	case *GotoStatement:
+		if node.Target == nil {
+			return fmt.Errorf("label %s used but not defined", node.Label)
+		}
+		i.jumpTarget = node.Target
+		i.seeking = false
+		return nil
+	case *LabelStatement, *CaseStatement:
+		// Labels only mark where a goto or switch resumes execution
+		if i.seeking && stmt == i.jumpTarget {
+			i.jumpTarget = nil
+			i.seeking = false
+		}
+		return nil
+	case *StructDecl:
+		i.structs[node.TypeName()] = node
+		delete(i.layouts, node.TypeName())
+		return nil
+	case *DeclarationList:
+		for _, decl := range node.Declarations {
+			if err := i.evalStatement(decl, env); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 	return nil
 }
 
 // evalVarDecl evaluates a variable declaration node within the given environment.
+// Storage for the variable is allocated in interpreter memory and starts out zeroed.
 // If the variable declaration includes an initial value, it evaluates the expression
-// and assigns the result to the variable. Otherwise, it initializes the variable with
-// a default value (zero for integers). The variable is then set in the environment.
-// Returns an error if evaluation of the initial value fails.
+// and stores the result in the variable; arrays, structs and unions are filled from
+// their initializer list. An array declared without a size is sized to fit its
+// initializer list. The variable is then bound in the environment.
+// A static variable declared inside a function is allocated and initialized only the
+// first time its declaration is reached, and an extern one is bound to the variable
+// of that name defined at file scope.
+// Returns an error if allocation or evaluation of the initial value fails.
 func (i *Interpreter) evalVarDecl(node *VarDecl, env *Environment) error {
-	var val *Value
+	switch node.Storage {
+	case "extern":
+		v, ok := i.globals.Get(node.Name)
+		if !ok {
+			return fmt.Errorf("undefined variable: %s", node.Name)
+		}
+		env.Set(node.Name, v)
+		return nil
+	case "static":
+		if v, ok := i.statics[node]; ok {
+			env.Set(node.Name, v)
+			return nil
+		}
+	}
 
-	if node.Value != nil {
-		var err error
-		val, err = i.evalExpression(node.Value, env)
+	typ, err := i.declaredType(node, env)
+	if err != nil {
+		return err
+	}
+
+	size := i.sizeOf(typ)
+	if size < 0 {
+		return fmt.Errorf("storage size of %s is unknown", node.Name)
+	}
+	// Variables declared at file scope or static live in static storage for the whole run
+	alloc := i.memory.alloc
+	if env == i.globals || node.Storage == "static" {
+		alloc = i.memory.allocStatic
+	}
+	addr, err := alloc(size, i.alignOf(typ))
+	if err != nil {
+		return err
+	}
+
+	// A declaration that a goto jumps over is not initialized, unless it is static
+	if node.Value != nil && (!i.seeking || node.Storage == "static") {
+		if err := i.initObject(addr, typ, node.Value, env); err != nil {
+			return err
+		}
+	}
+
+	v := &Variable{Type: typ, Addr: addr}
+	if node.Storage == "static" {
+		i.statics[node] = v
+	}
+	env.Set(node.Name, v)
+	return nil
+}
+
+// declaredType returns the type of the variable node declares. An array declared
+// without a size takes its size from its initializer list or string literal.
+func (i *Interpreter) declaredType(node *VarDecl, env *Environment) (string, error) {
+	typ := node.Type
+	if elemType, n := arrayElem(typ); isArrayType(typ) && n < 0 {
+		if str, ok := stringInitializer(typ, node.Value); ok {
+			typ = arrayOf(elemType, len(str.Value)+1)
+		} else if list, ok := node.Value.(*InitializerList); ok {
+			typ = arrayOf(elemType, i.countElements(elemType, list.Elements, env))
+		} else if node.Value != nil {
+			return "", fmt.Errorf("invalid initializer for array %s", node.Name)
+		}
+	}
+	return typ, nil
+}
+
+// initObject stores the initializer init into the object of the given type at addr.
+// A plain expression is evaluated and assigned; an array must be given an initializer
+// list, or a string literal if it is a char array. A list is consumed by initList,
+// and any elements left over are an error.
+func (i *Interpreter) initObject(addr int64, typ string, init Expression, env *Environment) error {
+	if str, ok := stringInitializer(typ, init); ok {
+		return i.initString(addr, typ, str.Value)
+	}
+
+	list, ok := init.(*InitializerList)
+	if !ok {
+		if isArrayType(typ) {
+			return fmt.Errorf("invalid initializer for array of type %s", typ)
+		}
+		val, err := i.evalExpression(init, env)
 		if err != nil {
 			return err
 		}
-	} else {
-		// Default initialization
-		val = &Value{Type: node.Type, Int: 0}
+		return i.store(addr, typ, val)
+	}
+
+	elems := list.Elements
+	if err := i.initList(addr, typ, &elems, env); err != nil {
+		return err
+	}
+	if len(elems) > 0 {
+		return fmt.Errorf("too many initializers for %s", typ)
+	}
+	return nil
+}
+
+// initList initializes the object of the given type at addr from the front of elems,
+// removing the elements it uses. The elements of an array and the members of a struct
+// are initialized in order, and a union initializes its first member. As in C, the
+// braces around a nested aggregate may be left out, in which case it takes as many
+// elements as it needs from the enclosing list: {1, 2, 3, 4} initializes an int[2][2]
+// the same way as {{1, 2}, {3, 4}}. Anything without an initializer keeps the zero
+// value it was allocated with.
+func (i *Interpreter) initList(addr int64, typ string, elems *[]Expression, env *Environment) error {
+	switch {
+	case isArrayType(typ):
+		elemType, n := arrayElem(typ)
+		elemSize := int64(i.sizeOf(elemType))
+		for idx := 0; idx < n && len(*elems) > 0; idx++ {
+			if err := i.initMember(addr+int64(idx)*elemSize, elemType, elems, env); err != nil {
+				return err
+			}
+		}
+	case isStructType(typ):
+		layout, err := i.structLayout(typ)
+		if err != nil {
+			return err
+		}
+		fields := i.structs[typ].Fields
+		if i.structs[typ].Kind == "union" {
+			fields = fields[:min(len(fields), 1)]
+		}
+		for _, field := range fields {
+			if len(*elems) == 0 {
+				break
+			}
+			offset := int64(layout.fields[field.Name].Offset)
+			if err := i.initMember(addr+offset, field.Type, elems, env); err != nil {
+				return err
+			}
+		}
+	case len(*elems) > 0:
+		return i.initMember(addr, typ, elems, env)
+	}
+	return nil
+}
+
+// initMember initializes one element or member of an aggregate from the front of
+// elems. A braced list initializes it completely, an aggregate without braces takes
+// its elements from elems, and otherwise the first element is assigned to it.
+func (i *Interpreter) initMember(addr int64, typ string, elems *[]Expression, env *Environment) error {
+	elem := (*elems)[0]
+	if _, ok := elem.(*InitializerList); ok || !i.bracesElided(typ, elem, env) {
+		*elems = (*elems)[1:]
+		return i.initObject(addr, typ, elem, env)
+	}
+	return i.initList(addr, typ, elems, env)
+}
+
+// bracesElided reports whether elem is the first of several initializers for an
+// aggregate of the given type whose braces were left out, rather than a single
+// expression that initializes it. An expression of the same struct type initializes
+// a struct member directly, and a string literal initializes a char array.
+func (i *Interpreter) bracesElided(typ string, elem Expression, env *Environment) bool {
+	if _, ok := stringInitializer(typ, elem); ok {
+		return false
+	}
+	if isArrayType(typ) {
+		return true
+	}
+	if !isStructType(typ) {
+		return false
+	}
+	elemType, err := i.typeOf(elem, env)
+	return err != nil || elemType != typ
+}
+
+// stringInitializer returns the string literal that init consists of when it
+// initializes the char array type typ, as in char s[] = "hi" or char s[] = {"hi"}.
+func stringInitializer(typ string, init Expression) (*StringLiteral, bool) {
+	if !isCharArray(typ) {
+		return nil, false
 	}
+	if list, ok := init.(*InitializerList); ok && len(list.Elements) == 1 {
+		init = list.Elements[0]
+	}
+	str, ok := init.(*StringLiteral)
+	return str, ok
+}
 
-	env.Set(node.Name, val)
+// initString copies the chars of s and a terminating NUL into the char array of the
+// given type at addr. As in C, the NUL is left out when the array has room only for
+// the chars themselves.
+func (i *Interpreter) initString(addr int64, typ string, s string) error {
+	_, n := arrayElem(typ)
+	if len(s) > n {
+		return fmt.Errorf("initializer-string for array of type %s is too long", typ)
+	}
+	b, err := i.memory.bytes(addr, n)
+	if err != nil {
+		return err
+	}
+	copy(b, s)
 	return nil
 }
 
+// countElements returns the number of elements of the given type that the initializer
+// list elems provides for an array declared without a size, such as int a[] = {1, 2, 3}.
+func (i *Interpreter) countElements(elemType string, elems []Expression, env *Environment) int {
+	n := 0
+	for len(elems) > 0 {
+		remaining := len(elems)
+		i.skipMember(elemType, &elems, env)
+		if len(elems) == remaining {
+			// An element type with no room for initializers, such as int[0]
+			elems = elems[1:]
+		}
+		n++
+	}
+	return n
+}
+
+// skipMember removes from the front of elems the initializers that initMember would
+// use for one object of the given type, without evaluating them.
+func (i *Interpreter) skipMember(typ string, elems *[]Expression, env *Environment) {
+	elem := (*elems)[0]
+	if _, ok := elem.(*InitializerList); ok || !i.bracesElided(typ, elem, env) {
+		*elems = (*elems)[1:]
+		return
+	}
+
+	switch {
+	case isArrayType(typ):
+		elemType, n := arrayElem(typ)
+		for idx := 0; idx < n && len(*elems) > 0; idx++ {
+			i.skipMember(elemType, elems, env)
+		}
+	case isStructType(typ):
+		decl, ok := i.structs[typ]
+		if !ok || len(decl.Fields) == 0 {
+			*elems = (*elems)[1:]
+			return
+		}
+		fields := decl.Fields
+		if decl.Kind == "union" {
+			fields = fields[:1]
+		}
+		for _, field := range fields {
+			if len(*elems) == 0 {
+				break
+			}
+			i.skipMember(field.Type, elems, env)
+		}
+	}
+}
+
 // evalBlockStatement evaluates each statement within the provided BlockStatement
-// in the given Environment. It processes statements sequentially, stopping early
-// if a return, break, or continue condition is triggered. Returns an error if
-// any statement evaluation fails, otherwise returns nil.
+// in a new scope enclosed by the given Environment, so that declarations in the block
+// shadow outer variables of the same name and are not visible once the block ends.
+// Storage allocated by the block is released when it is left. Statements are processed
+// sequentially, stopping early if a return, break, continue or goto condition is triggered.
+// Returns an error if any statement evaluation fails, otherwise returns nil.
 func (i *Interpreter) evalBlockStatement(block *BlockStatement, env *Environment) error {
-	for _, stmt := range block.Statements {
+	mark := i.memory.mark()
+	defer i.memory.release(mark)
+
+	return i.evalStatements(block.Statements, 0, NewEnclosedEnvironment(env))
+}
+
+// evalStatements evaluates stmts in order beginning at index start, stopping early if
+// a return, break, continue or goto interrupts execution. A goto whose label lies
+// within stmts is resolved here: execution moves to the statement holding the label,
+// forwards or backwards, and seeks down into it. While seeking, statements that do
+// not contain the target label are skipped, apart from declarations.
+func (i *Interpreter) evalStatements(stmts []Statement, start int, env *Environment) error {
+	for idx := start; idx < len(stmts); idx++ {
+		stmt := stmts[idx]
+		if i.seeking && !containsLabel(stmt, i.jumpTarget) {
+			if err := i.declareSkipped(stmt, env); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if err := i.evalStatement(stmt, env); err != nil {
 			return err
 		}
+
+		if i.jumping() {
+			target := findLabel(stmts, i.jumpTarget)
+			if target < 0 {
+				break
+			}
+			i.seeking = true
+			// Variables declared between the goto and its label are in scope there
+			for skipped := idx + 1; skipped < target; skipped++ {
+				if err := i.declareSkipped(stmts[skipped], env); err != nil {
+					return err
+				}
+			}
+			idx = target - 1
+			continue
+		}
 		if i.shouldReturn || i.shouldBreak || i.shouldContinue {
 			break
 		}
@@ -309,13 +725,109 @@ func (i *Interpreter) evalBlockStatement(block *BlockStatement, env *Environment
 	return nil
 }
 
+// declareSkipped carries out stmt, which a goto is jumping over, if it declares
+// something. As in C, variables declared before the label are in scope at the label,
+// but their initializers are not evaluated, since evalVarDecl skips them while seeking.
+func (i *Interpreter) declareSkipped(stmt Statement, env *Environment) error {
+	switch stmt.(type) {
+	case *VarDecl, *DeclarationList, *StructDecl:
+		return i.evalStatement(stmt, env)
+	}
+	return nil
+}
+
+// jumping reports whether a goto is unwinding towards the statement list that
+// contains its label.
+func (i *Interpreter) jumping() bool {
+	return i.jumpTarget != nil && !i.seeking
+}
+
+// findLabel returns the index of the statement in stmts that is, or contains,
+// the given label, or -1 if there is none.
+func findLabel(stmts []Statement, label Statement) int {
+	for idx, stmt := range stmts {
+		if containsLabel(stmt, label) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// containsLabel reports whether stmt is the given label or contains it anywhere
+// within its nested statements.
+func containsLabel(stmt Statement, label Statement) bool {
+	if stmt == label {
+		return true
+	}
+	for _, block := range nestedBlocks(stmt) {
+		if findLabel(block.Statements, label) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedBlocks returns the blocks of statements directly nested within stmt.
+func nestedBlocks(stmt Statement) []*BlockStatement {
+	var body []*BlockStatement
+	switch node := stmt.(type) {
+	case *BlockStatement:
+		body = append(body, node)
+	case *IfStatement:
+		body = append(body, node.Consequence, node.Alternative)
+	case *WhileStatement:
+		body = append(body, node.Body)
+	case *DoWhileStatement:
+		body = append(body, node.Body)
+	case *ForStatement:
+		body = append(body, node.Body)
+	case *SwitchStatement:
+		body = append(body, node.Body)
+	}
+
+	blocks := body[:0]
+	for _, block := range body {
+		if block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// switchCases returns the case labels of a switch whose body holds stmts, in order,
+// including those nested within blocks and loops but not those of nested switches.
+func switchCases(stmts []Statement) []*CaseStatement {
+	var cases []*CaseStatement
+	for _, stmt := range stmts {
+		switch node := stmt.(type) {
+		case *CaseStatement:
+			cases = append(cases, node)
+		case *SwitchStatement:
+			continue
+		}
+		for _, block := range nestedBlocks(stmt) {
+			cases = append(cases, switchCases(block.Statements)...)
+		}
+	}
+	return cases
+}
+
 // evalIfStatement evaluates an IfStatement node within the given environment.
 // It first evaluates the condition expression. If the condition is truthy,
 // it evaluates and returns the result of the consequence block. If the condition
 // is falsy and an alternative block exists, it evaluates and returns the result
 // of the alternative block. If neither block is executed, it returns nil.
+// When a goto is seeking a label inside one of the blocks, that block is entered directly.
 // Returns an error if evaluating the condition or any block fails.
 func (i *Interpreter) evalIfStatement(node *IfStatement, env *Environment) error {
+	// A goto into one of the branches enters it without testing the condition
+	if i.seeking {
+		if node.Consequence != nil && findLabel(node.Consequence.Statements, i.jumpTarget) >= 0 {
+			return i.evalBlockStatement(node.Consequence, env)
+		}
+		return i.evalBlockStatement(node.Alternative, env)
+	}
+
 	condition, err := i.evalExpression(node.Condition, env)
 	if err != nil {
 		return err
@@ -333,26 +845,55 @@ func (i *Interpreter) evalIfStatement(node *IfStatement, env *Environment) error
 // evalWhileStatement evaluates a WhileStatement node within the given environment.
 // It repeatedly evaluates the loop's condition and executes the loop body as long as the condition is truthy.
 // The method handles control flow statements such as return, break, and continue by checking the interpreter's flags:
-// - If shouldReturn is set, the loop breaks to allow return propagation.
+// - If shouldReturn is set, or a goto leaves the body, the loop breaks to allow propagation.
 // - If shouldBreak is set, the flag is reset and the loop breaks.
 // - If shouldContinue is set, the flag is reset and the loop continues to the next iteration.
 // Returns an error if evaluating the condition or body fails.
 func (i *Interpreter) evalWhileStatement(node *WhileStatement, env *Environment) error {
 	for {
-		condition, err := i.evalExpression(node.Condition, env)
-		if err != nil {
+		// A goto into the body skips the first test of the condition
+		if !i.seeking {
+			condition, err := i.evalExpression(node.Condition, env)
+			if err != nil {
+				return err
+			}
+
+			if !i.isTruthy(condition) {
+				break
+			}
+		}
+
+		if err := i.evalBlockStatement(node.Body, env); err != nil {
 			return err
 		}
 
-		if !i.isTruthy(condition) {
+		if i.shouldReturn || i.jumping() {
+			break
+		}
+		if i.shouldBreak {
+			i.shouldBreak = false
 			break
 		}
+		if i.shouldContinue {
+			i.shouldContinue = false
+			continue
+		}
+	}
+	return nil
+}
 
+// evalDoWhileStatement evaluates a DoWhileStatement node within the given environment.
+// It executes the loop body once and then keeps executing it for as long as the
+// condition, evaluated after each pass, is truthy. Control flow flags are handled
+// as in evalWhileStatement; a continue skips to the evaluation of the condition.
+// Returns an error if evaluating the body or condition fails.
+func (i *Interpreter) evalDoWhileStatement(node *DoWhileStatement, env *Environment) error {
+	for {
 		if err := i.evalBlockStatement(node.Body, env); err != nil {
 			return err
 		}
 
-		if i.shouldReturn {
+		if i.shouldReturn || i.jumping() {
 			break
 		}
 		if i.shouldBreak {
@@ -361,7 +902,15 @@ func (i *Interpreter) evalWhileStatement(node *WhileStatement, env *Environment)
 		}
 		if i.shouldContinue {
 			i.shouldContinue = false
-			continue
+		}
+
+		condition, err := i.evalExpression(node.Condition, env)
+		if err != nil {
+			return err
+		}
+
+		if !i.isTruthy(condition) {
+			break
 		}
 	}
 	return nil
@@ -377,8 +926,8 @@ func (i *Interpreter) evalForStatement(node *ForStatement, env *Environment) err
 	// Create new scope for the loop
 	loopEnv := NewEnclosedEnvironment(env)
 
-	// Initialize
-	if node.Init != nil {
+	// Initialize, unless a goto is entering the body directly
+	if node.Init != nil && !i.seeking {
 		if err := i.evalStatement(node.Init, loopEnv); err != nil {
 			return err
 		}
@@ -386,7 +935,7 @@ func (i *Interpreter) evalForStatement(node *ForStatement, env *Environment) err
 
 	for {
 		// Check condition
-		if node.Condition != nil {
+		if node.Condition != nil && !i.seeking {
 			condition, err := i.evalExpression(node.Condition, loopEnv)
 			if err != nil {
 				return err
@@ -401,7 +950,7 @@ func (i *Interpreter) evalForStatement(node *ForStatement, env *Environment) err
 			return err
 		}
 
-		if i.shouldReturn {
+		if i.shouldReturn || i.jumping() {
 			break
 		}
 		if i.shouldBreak {
@@ -423,6 +972,69 @@ func (i *Interpreter) evalForStatement(node *ForStatement, env *Environment) err
 	return nil
 }
 
+// evalSwitchStatement evaluates a SwitchStatement node within the given environment.
+// It evaluates the controlling expression, then finds the 'case' label with a matching
+// value within the body, or the 'default' label if none matches, wherever it appears,
+// even inside a nested block or loop. Execution jumps to that label, as a goto would,
+// and runs to the end of the body, falling through any later labels, unless a break,
+// continue, return or goto intervenes. A break ends the switch; continue and return are
+// left for an enclosing loop or function. A goto seeking a label inside the body enters
+// it without evaluating the expression.
+// Returns an error if the controlling expression is not an integer or evaluation fails.
+func (i *Interpreter) evalSwitchStatement(node *SwitchStatement, env *Environment) error {
+	if !i.seeking {
+		label, err := i.switchLabel(node, env)
+		if err != nil || label == nil {
+			return err
+		}
+		i.jumpTarget = label
+		i.seeking = true
+	}
+
+	// The body is a compound statement with a scope of its own
+	mark := i.memory.mark()
+	defer i.memory.release(mark)
+
+	if err := i.evalStatements(node.Body.Statements, 0, NewEnclosedEnvironment(env)); err != nil {
+		return err
+	}
+
+	i.shouldBreak = false
+	return nil
+}
+
+// switchLabel evaluates the controlling expression of a switch and returns the label
+// where execution begins: the 'case' label with a matching value, otherwise the
+// 'default' label, or nil if neither exists. The value and the case values are
+// compared after conversion to the promoted type of the controlling expression.
+func (i *Interpreter) switchLabel(node *SwitchStatement, env *Environment) (*CaseStatement, error) {
+	value, err := i.evalExpression(node.Value, env)
+	if err != nil {
+		return nil, err
+	}
+	if isFloatType(value.Type) || isPointerType(value.Type) || isStructType(value.Type) {
+		return nil, fmt.Errorf("switch quantity not an integer")
+	}
+	typ := promote(value.Type)
+	value = convert(value, typ)
+
+	var defaultLabel *CaseStatement
+	for _, label := range switchCases(node.Body.Statements) {
+		if label.Value == nil {
+			defaultLabel = label
+			continue
+		}
+		caseValue, err := i.evalExpression(label.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		if convert(caseValue, typ).Int == value.Int {
+			return label, nil
+		}
+	}
+	return defaultLabel, nil
+}
+
 // evalFunctionBody evaluates the body of a function represented by the given BlockStatement
 // within the provided Environment. It resets the interpreter's return state before execution.
 // If a return statement is encountered during evaluation, the corresponding value is returned.
@@ -450,19 +1062,28 @@ func (i *Interpreter) evalFunctionBody(block *BlockStatement, env *Environment)
 func (i *Interpreter) evalExpression(expr Expression, env *Environment) (*Value, error) {
 	switch node := expr.(type) {
 	case *IntegerLiteral:
-		return &Value{Type: "int", Int: node.Value}, nil
+		return &Value{Type: node.Type, Int: node.Value}, nil
 	case *FloatLiteral:
-		return &Value{Type: "float", Float: node.Value}, nil
+		return &Value{Type: node.Type, Float: node.Value}, nil
 	case *StringLiteral:
-		return &Value{Type: "string", Str: node.Value}, nil
+		// A string literal is a static char array, which decays to a pointer to its first char
+		addr, err := i.internString(node.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Type: "char*", Int: addr}, nil
 	case *CharLiteral:
-		return &Value{Type: "char", Int: int64(node.Value)}, nil
+		// A character constant is an int holding the value of the char
+		return &Value{Type: "int", Int: int64(int8(node.Value))}, nil
 	case *Identifier:
-		val, ok := env.Get(node.Value)
+		v, ok := env.Get(node.Value)
 		if !ok {
 			return nil, fmt.Errorf("undefined variable: %s", node.Value)
 		}
-		return val, nil
+		if isArrayType(v.Type) {
+			return i.decay(v.Addr, v.Type), nil
+		}
+		return i.load(v.Addr, v.Type)
 	case *PrefixExpression:
 		return i.evalPrefixExpression(node, env)
 	case *PostfixExpression:
@@ -473,8 +1094,25 @@ func (i *Interpreter) evalExpression(expr Expression, env *Environment) (*Value,
 		return i.evalAssignmentExpression(node, env)
 	case *CallExpression:
 		return i.evalCallExpression(node, env)
+	case *CastExpression:
+		return i.evalCastExpression(node, env)
+	case *SizeofExpression:
+		return i.evalSizeofExpression(node, env)
 	case *ConditionalExpression:
 		return i.evalConditionalExpression(node, env)
+	case *ArrayExpression:
+		return i.evalArrayExpression(node, env)
+	case *MemberExpression:
+		addr, typ, err := i.evalMemberAddress(node, env)
+		if err != nil {
+			return nil, err
+		}
+		if isArrayType(typ) {
+			return i.decay(addr, typ), nil
+		}
+		return i.load(addr, typ)
+	case *InitializerList:
+		return nil, fmt.Errorf("initializer list is only allowed in a declaration")
 	}
 	return nil, fmt.Errorf("unknown expression type")
 }
@@ -484,39 +1122,55 @@ func (i *Interpreter) evalExpression(expr Expression, env *Environment) (*Value,
 //   - "-"  : Negates the value (supports both int and float types).
 //   - "!"  : Logical NOT, returns 1 if the value is falsy, 0 otherwise.
 //   - "~"  : Bitwise NOT, applies only to int values.
-//   - "++" : Pre-increment, increments the value of an identifier before returning it.
-//   - "--" : Pre-decrement, decrements the value of an identifier before returning it.
+//   - "&"  : Address-of, returns a pointer to its operand, which must be an lvalue.
+//   - "*"  : Dereference, returns the value stored where a pointer points.
+//   - "++" : Pre-increment, increments an lvalue before returning its new value.
+//   - "--" : Pre-decrement, decrements an lvalue before returning its new value.
 // Returns the evaluated Value or an error if the operator is unknown or evaluation fails.
 func (i *Interpreter) evalPrefixExpression(node *PrefixExpression, env *Environment) (*Value, error) {
-	right, err := i.evalExpression(node.Right, env)
-	if err != nil {
-		return nil, err
-	}
+	switch node.Operator {
+	case "&":
+		addr, typ, err := i.evalAddress(node.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Type: typ + "*", Int: addr}, nil
+	case "++":
+		// Pre-increment
+		_, val, err := i.addToLValue(node.Right, 1, env)
+		return val, err
+	case "--":
+		// Pre-decrement
+		_, val, err := i.addToLValue(node.Right, -1, env)
+		return val, err
+	}
+
+	right, err := i.evalExpression(node.Right, env)
+	if err != nil {
+		return nil, err
+	}
 
 	switch node.Operator {
 	case "-":
-		if right.Type == "float" {
-			return &Value{Type: "float", Float: -right.Float}, nil
+		if isFloatType(right.Type) {
+			return &Value{Type: right.Type, Float: -right.Float}, nil
 		}
-		return &Value{Type: "int", Int: -right.Int}, nil
+		typ := commonIntType(right.Type, right.Type)
+		return &Value{Type: typ, Int: convertInt(-right.Int, typ)}, nil
 	case "!":
 		return &Value{Type: "int", Int: boolToInt(!i.isTruthy(right))}, nil
 	case "~":
-		return &Value{Type: "int", Int: ^right.Int}, nil
-	case "++":
-		// Pre-increment
-		if ident, ok := node.Right.(*Identifier); ok {
-			val, _ := env.Get(ident.Value)
-			val.Int++
-			return val, nil
+		typ := commonIntType(right.Type, right.Type)
+		return &Value{Type: typ, Int: convertInt(^right.Int, typ)}, nil
+	case "*":
+		if !isPointerType(right.Type) {
+			return nil, fmt.Errorf("cannot dereference non-pointer value of type %s", right.Type)
 		}
-	case "--":
-		// Pre-decrement
-		if ident, ok := node.Right.(*Identifier); ok {
-			val, _ := env.Get(ident.Value)
-			val.Int--
-			return val, nil
+		elemType := pointerElem(right.Type)
+		if isArrayType(elemType) {
+			return i.decay(right.Int, elemType), nil
 		}
+		return i.load(right.Int, elemType)
 	}
 
 	return nil, fmt.Errorf("unknown prefix operator: %s", node.Operator)
@@ -525,28 +1179,15 @@ func (i *Interpreter) evalPrefixExpression(node *PrefixExpression, env *Environm
 // evalPostfixExpression evaluates a postfix expression (such as increment '++' or decrement '--')
 // for the given AST node and environment. It returns the value of the expression before the postfix
 // operation is applied, as per C-like semantics. If the operator is not recognized, an error is returned.
-// Only identifiers can be incremented or decremented; otherwise, the function returns an error.
+// The operand must be an lvalue; otherwise, the function returns an error.
 func (i *Interpreter) evalPostfixExpression(node *PostfixExpression, env *Environment) (*Value, error) {
-	left, err := i.evalExpression(node.Left, env)
-	if err != nil {
-		return nil, err
-	}
-
-	oldValue := &Value{Type: left.Type, Int: left.Int, Float: left.Float}
-
 	switch node.Operator {
 	case "++":
-		if ident, ok := node.Left.(*Identifier); ok {
-			val, _ := env.Get(ident.Value)
-			val.Int++
-		}
-		return oldValue, nil
+		oldValue, _, err := i.addToLValue(node.Left, 1, env)
+		return oldValue, err
 	case "--":
-		if ident, ok := node.Left.(*Identifier); ok {
-			val, _ := env.Get(ident.Value)
-			val.Int--
-		}
-		return oldValue, nil
+		oldValue, _, err := i.addToLValue(node.Left, -1, env)
+		return oldValue, err
 	}
 
 	return nil, fmt.Errorf("unknown postfix operator: %s", node.Operator)
@@ -561,10 +1202,22 @@ func (i *Interpreter) evalPostfixExpression(node *PostfixExpression, env *Enviro
 // Supported operators:
 //   - Arithmetic: +, -, *, /, %
 //   - Comparison: <, >, <=, >=, ==, !=
-//   - Logical: &&, ||
+//   - Logical: &&, ||, which short-circuit
 //   - Bitwise: &, |, ^, <<, >>
-// For float operands, only arithmetic and comparison operators are supported.
+//   - Comma: evaluates the left operand, then yields the right one
+// For float operands, only arithmetic, comparison and logical operators are supported.
 func (i *Interpreter) evalInfixExpression(node *InfixExpression, env *Environment) (*Value, error) {
+	// These operators decide whether and when the right operand is evaluated
+	switch node.Operator {
+	case "&&", "||":
+		return i.evalLogicalExpression(node, env)
+	case ",":
+		if _, err := i.evalExpression(node.Left, env); err != nil {
+			return nil, err
+		}
+		return i.evalExpression(node.Right, env)
+	}
+
 	left, err := i.evalExpression(node.Left, env)
 	if err != nil {
 		return nil, err
@@ -575,26 +1228,42 @@ func (i *Interpreter) evalInfixExpression(node *InfixExpression, env *Environmen
 		return nil, err
 	}
 
-	// Handle float operations
-	if left.Type == "float" || right.Type == "float" {
-		leftF := left.Float
-		if left.Type != "float" {
-			leftF = float64(left.Int)
-		}
-		rightF := right.Float
-		if right.Type != "float" {
-			rightF = float64(right.Int)
-		}
+	// Handle pointer arithmetic
+	if (isPointerType(left.Type) || isPointerType(right.Type)) && (node.Operator == "+" || node.Operator == "-") {
+		return i.pointerArithmetic(node.Operator, left, right)
+	}
 
-		switch node.Operator {
+	return i.arithmetic(node.Operator, left, right)
+}
+
+// arithmetic applies the binary arithmetic, comparison or bitwise operator to two
+// operand values. If either operand is floating-point, both are converted to the
+// floating type of the operation, which is double if either operand is a double and
+// float otherwise; float results are rounded to float precision. Otherwise the usual arithmetic conversions bring both operands to a common integer
+// type, the operation is carried out in that type, and the result wraps around as the
+// type's width dictates; unsigned operands divide, compare and shift right as unsigned
+// numbers. Comparisons yield an int of 0 or 1, and a shift takes the promoted type of
+// its left operand. Returns an error for division by zero or an unknown operator.
+func (i *Interpreter) arithmetic(operator string, left, right *Value) (*Value, error) {
+	if isFloatType(left.Type) || isFloatType(right.Type) {
+		leftF := floatValue(left)
+		rightF := floatValue(right)
+
+		// The operation is done in double if either operand is a double, otherwise in float
+		typ := "float"
+		if left.Type == "double" || right.Type == "double" {
+			typ = "double"
+		}
+
+		switch operator {
 		case "+":
-			return &Value{Type: "float", Float: leftF + rightF}, nil
+			return &Value{Type: typ, Float: roundFloat(leftF+rightF, typ)}, nil
 		case "-":
-			return &Value{Type: "float", Float: leftF - rightF}, nil
+			return &Value{Type: typ, Float: roundFloat(leftF-rightF, typ)}, nil
 		case "*":
-			return &Value{Type: "float", Float: leftF * rightF}, nil
+			return &Value{Type: typ, Float: roundFloat(leftF*rightF, typ)}, nil
 		case "/":
-			return &Value{Type: "float", Float: leftF / rightF}, nil
+			return &Value{Type: typ, Float: roundFloat(leftF/rightF, typ)}, nil
 		case "<":
 			return &Value{Type: "int", Int: boolToInt(leftF < rightF)}, nil
 		case ">":
@@ -608,114 +1277,423 @@ func (i *Interpreter) evalInfixExpression(node *InfixExpression, env *Environmen
 		case "!=":
 			return &Value{Type: "int", Int: boolToInt(leftF != rightF)}, nil
 		}
+		return nil, fmt.Errorf("invalid operands to binary %s: %s and %s", operator, left.Type, right.Type)
 	}
 
-	// Integer operations
-	switch node.Operator {
+	// Shifts take the type of the promoted left operand
+	if operator == "<<" || operator == ">>" {
+		typ := promote(left.Type)
+		if !isIntegerType(typ) {
+			return nil, fmt.Errorf("invalid operands to binary %s: %s and %s", operator, left.Type, right.Type)
+		}
+		count := uint64(right.Int) & 63
+		n := convertInt(left.Int, typ)
+		if operator == "<<" {
+			n <<= count
+		} else if isUnsignedType(typ) {
+			n = int64(uint64(n) >> count)
+		} else {
+			n >>= count
+		}
+		return &Value{Type: typ, Int: convertInt(n, typ)}, nil
+	}
+
+	typ := commonIntType(left.Type, right.Type)
+	l, r := convertInt(left.Int, typ), convertInt(right.Int, typ)
+	unsigned := isUnsignedType(typ)
+
+	var n int64
+	switch operator {
 	case "+":
-		return &Value{Type: "int", Int: left.Int + right.Int}, nil
+		n = l + r
 	case "-":
-		return &Value{Type: "int", Int: left.Int - right.Int}, nil
+		n = l - r
 	case "*":
-		return &Value{Type: "int", Int: left.Int * right.Int}, nil
-	case "/":
-		if right.Int == 0 {
+		n = l * r
+	case "/", "%":
+		if r == 0 {
 			return nil, fmt.Errorf("division by zero")
 		}
-		return &Value{Type: "int", Int: left.Int / right.Int}, nil
-	case "%":
-		return &Value{Type: "int", Int: left.Int % right.Int}, nil
+		switch {
+		case unsigned && operator == "/":
+			n = int64(uint64(l) / uint64(r))
+		case unsigned:
+			n = int64(uint64(l) % uint64(r))
+		case operator == "/":
+			n = l / r
+		default:
+			n = l % r
+		}
+	case "&":
+		n = l & r
+	case "|":
+		n = l | r
+	case "^":
+		n = l ^ r
+	case "<", ">", "<=", ">=", "==", "!=":
+		return &Value{Type: "int", Int: boolToInt(compareInts(operator, l, r, unsigned))}, nil
+	default:
+		return nil, fmt.Errorf("unknown infix operator: %s", operator)
+	}
+	return &Value{Type: typ, Int: convertInt(n, typ)}, nil
+}
+
+// compareInts compares two integers that have been converted to a common type,
+// treating their bits as unsigned numbers if that type is unsigned.
+func compareInts(operator string, l, r int64, unsigned bool) bool {
+	if unsigned {
+		ul, ur := uint64(l), uint64(r)
+		switch operator {
+		case "<":
+			return ul < ur
+		case ">":
+			return ul > ur
+		case "<=":
+			return ul <= ur
+		case ">=":
+			return ul >= ur
+		}
+	}
+
+	switch operator {
 	case "<":
-		return &Value{Type: "int", Int: boolToInt(left.Int < right.Int)}, nil
+		return l < r
 	case ">":
-		return &Value{Type: "int", Int: boolToInt(left.Int > right.Int)}, nil
+		return l > r
 	case "<=":
-		return &Value{Type: "int", Int: boolToInt(left.Int <= right.Int)}, nil
+		return l <= r
 	case ">=":
-		return &Value{Type: "int", Int: boolToInt(left.Int >= right.Int)}, nil
+		return l >= r
 	case "==":
-		return &Value{Type: "int", Int: boolToInt(left.Int == right.Int)}, nil
-	case "!=":
-		return &Value{Type: "int", Int: boolToInt(left.Int != right.Int)}, nil
-	case "&&":
-		return &Value{Type: "int", Int: boolToInt(i.isTruthy(left) && i.isTruthy(right))}, nil
-	case "||":
-		return &Value{Type: "int", Int: boolToInt(i.isTruthy(left) || i.isTruthy(right))}, nil
-	case "&":
-		return &Value{Type: "int", Int: left.Int & right.Int}, nil
-	case "|":
-		return &Value{Type: "int", Int: left.Int | right.Int}, nil
-	case "^":
-		return &Value{Type: "int", Int: left.Int ^ right.Int}, nil
-	case "<<":
-		return &Value{Type: "int", Int: left.Int << uint(right.Int)}, nil
-	case ">>":
-		return &Value{Type: "int", Int: left.Int >> uint(right.Int)}, nil
+		return l == r
 	}
-
-	return nil, fmt.Errorf("unknown infix operator: %s", node.Operator)
+	return l != r
 }
 
 // evalAssignmentExpression evaluates an assignment expression node within the given environment.
 // It supports both simple assignments (e.g., x = 5) and compound assignments (e.g., x += 2).
-// The function first evaluates the right-hand side expression. If the left-hand side is an identifier,
-// it performs the assignment or compound operation, updating the environment accordingly.
-// Returns the resulting value of the assignment or an error if the operation is invalid or the variable is undefined.
+// The function first evaluates the right-hand side expression, then resolves the left-hand side
+// to the address of the object it designates: a variable, an array element, or the target of a
+// dereferenced pointer, or a struct or union member. The result is stored in memory at that address.
+// Returns the resulting value of the assignment or an error if the target is not assignable.
 func (i *Interpreter) evalAssignmentExpression(node *AssignmentExpression, env *Environment) (*Value, error) {
 	right, err := i.evalExpression(node.Right, env)
 	if err != nil {
 		return nil, err
 	}
 
-	if ident, ok := node.Left.(*Identifier); ok {
-		if node.Operator == "=" {
-			env.Set(ident.Value, right)
-			return right, nil
+	addr, typ, err := i.evalAddress(node.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	if isArrayType(typ) {
+		return nil, fmt.Errorf("cannot assign to array %s", node.Left.String())
+	}
+
+	result := right
+	if node.Operator != "=" {
+		if isStructType(typ) {
+			return nil, fmt.Errorf("invalid operands to %s: %s", node.Operator, typ)
 		}
+		left, err := i.load(addr, typ)
+		if err != nil {
+			return nil, err
+		}
+		result, err = i.applyCompoundOperator(node.Operator, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := i.store(addr, typ, result); err != nil {
+		return nil, err
+	}
+	return i.load(addr, typ)
+}
+
+// applyCompoundOperator computes the new value of the left operand of a compound
+// assignment such as "+=" or "<<=". The operation is carried out exactly as the
+// corresponding binary operator would, and the result is converted back to the type
+// of the left operand when it is stored. Adding to or subtracting from a pointer is
+// scaled by the size of what it points to.
+// Returns an error if the operator is not a compound assignment operator.
+func (i *Interpreter) applyCompoundOperator(operator string, left, right *Value) (*Value, error) {
+	if isPointerType(left.Type) && (operator == "+=" || operator == "-=") {
+		return i.pointerArithmetic(operator[:1], left, right)
+	}
+	if !strings.HasSuffix(operator, "=") {
+		return nil, fmt.Errorf("unknown assignment operator: %s", operator)
+	}
+	return i.arithmetic(strings.TrimSuffix(operator, "="), left, right)
+}
+
+// evalLogicalExpression evaluates a && or || expression. The left operand is evaluated
+// first, and the right operand only when it can still change the outcome: for && when
+// the left operand is true, and for || when it is false. The result is the int 1 or 0.
+func (i *Interpreter) evalLogicalExpression(node *InfixExpression, env *Environment) (*Value, error) {
+	left, err := i.evalExpression(node.Left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.isTruthy(left) == (node.Operator == "||") {
+		return &Value{Type: "int", Int: boolToInt(i.isTruthy(left))}, nil
+	}
 
-		// Compound assignment
-		left, ok := env.Get(ident.Value)
+	right, err := i.evalExpression(node.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	return &Value{Type: "int", Int: boolToInt(i.isTruthy(right))}, nil
+}
+
+// evalArrayExpression evaluates a subscript such as a[i], which C defines as *(a + i),
+// and returns the element's value. When the element is itself an array, as with m[i]
+// for a two-dimensional array m, the result is a pointer to that row's first element.
+func (i *Interpreter) evalArrayExpression(node *ArrayExpression, env *Environment) (*Value, error) {
+	addr, elemType, err := i.evalElementAddress(node, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if isArrayType(elemType) {
+		return i.decay(addr, elemType), nil
+	}
+	return i.load(addr, elemType)
+}
+
+// evalElementAddress evaluates the operands of a subscript and returns the memory address
+// and type of the selected element. One operand must be a pointer, which arrays decay to,
+// and the other an integer; as in C, i[a] selects the same element as a[i].
+func (i *Interpreter) evalElementAddress(node *ArrayExpression, env *Environment) (int64, string, error) {
+	base, err := i.evalExpression(node.Left, env)
+	if err != nil {
+		return 0, "", err
+	}
+
+	index, err := i.evalExpression(node.Index, env)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if isPointerType(index.Type) {
+		base, index = index, base
+	}
+	if !isPointerType(base.Type) {
+		return 0, "", fmt.Errorf("subscripted value is not an array or pointer: %s", node.Left.String())
+	}
+	if isFloatType(index.Type) {
+		return 0, "", fmt.Errorf("array subscript is not an integer")
+	}
+
+	elem, err := i.pointerArithmetic("+", base, index)
+	if err != nil {
+		return 0, "", err
+	}
+	return elem.Int, pointerElem(base.Type), nil
+}
+
+// decay converts the array of the given type at addr into a pointer to its first
+// element, as C does whenever an array is used as a value.
+func (i *Interpreter) decay(addr int64, arrayType string) *Value {
+	elemType, _ := arrayElem(arrayType)
+	return &Value{Type: elemType + "*", Int: addr}
+}
+
+// pointerArithmetic evaluates "+" and "-" where at least one operand is a pointer.
+// Adding an integer n to a pointer advances it by n elements of the type it points to,
+// and subtracting two pointers yields the number of elements between them.
+// Returns an error for combinations C does not allow, such as adding two pointers.
+func (i *Interpreter) pointerArithmetic(operator string, left, right *Value) (*Value, error) {
+	leftPtr := isPointerType(left.Type)
+	rightPtr := isPointerType(right.Type)
+
+	if leftPtr && rightPtr {
+		if operator != "-" {
+			return nil, fmt.Errorf("invalid operands to binary %s: %s and %s", operator, left.Type, right.Type)
+		}
+		size := i.elemSize(left.Type)
+		return &Value{Type: "long", Int: (left.Int - right.Int) / size}, nil
+	}
+
+	ptr, offset := left, right
+	if rightPtr {
+		if operator == "-" {
+			return nil, fmt.Errorf("invalid operands to binary -: %s and %s", left.Type, right.Type)
+		}
+		ptr, offset = right, left
+	}
+	if isFloatType(offset.Type) {
+		return nil, fmt.Errorf("invalid operands to binary %s: %s and %s", operator, left.Type, right.Type)
+	}
+
+	delta := offset.Int * i.elemSize(ptr.Type)
+	if operator == "-" {
+		delta = -delta
+	}
+	return &Value{Type: ptr.Type, Int: ptr.Int + delta}, nil
+}
+
+// elemSize returns the size of the type a pointer type points to, which is the
+// distance pointer arithmetic moves per element. Pointers to types without a size,
+// such as void*, move one byte at a time.
+func (i *Interpreter) elemSize(ptrType string) int64 {
+	if size := i.sizeOf(pointerElem(ptrType)); size > 0 {
+		return int64(size)
+	}
+	return 1
+}
+
+// evalAddress resolves an lvalue expression to the address and type of the object it
+// designates. Variables, array elements, dereferenced pointers, struct or union members
+// and string literals are lvalues; any other expression results in an error. Every write to an object,
+// whether by assignment, compound assignment or ++ and --, goes through this address,
+// so a variable found in an enclosing scope is updated in place rather than shadowed.
+func (i *Interpreter) evalAddress(expr Expression, env *Environment) (int64, string, error) {
+	switch node := expr.(type) {
+	case *Identifier:
+		v, ok := env.Get(node.Value)
 		if !ok {
-			return nil, fmt.Errorf("undefined variable: %s", ident.Value)
-		}
-
-		var result *Value
-		switch node.Operator {
-		case "+=":
-			result = &Value{Type: left.Type, Int: left.Int + right.Int}
-		case "-=":
-			result = &Value{Type: left.Type, Int: left.Int - right.Int}
-		case "*=":
-			result = &Value{Type: left.Type, Int: left.Int * right.Int}
-		case "/=":
-			result = &Value{Type: left.Type, Int: left.Int / right.Int}
-		case "%=":
-			result = &Value{Type: left.Type, Int: left.Int % right.Int}
-		case "&=":
-			result = &Value{Type: left.Type, Int: left.Int & right.Int}
-		case "|=":
-			result = &Value{Type: left.Type, Int: left.Int | right.Int}
-		case "^=":
-			result = &Value{Type: left.Type, Int: left.Int ^ right.Int}
-		case "<<=":
-			result = &Value{Type: left.Type, Int: left.Int << uint(right.Int)}
-		case ">>=":
-			result = &Value{Type: left.Type, Int: left.Int >> uint(right.Int)}
-		default:
-			return nil, fmt.Errorf("unknown assignment operator: %s", node.Operator)
+			return 0, "", fmt.Errorf("undefined variable: %s", node.Value)
+		}
+		return v.Addr, v.Type, nil
+	case *ArrayExpression:
+		return i.evalElementAddress(node, env)
+	case *MemberExpression:
+		return i.evalMemberAddress(node, env)
+	case *StringLiteral:
+		addr, err := i.internString(node.Value)
+		if err != nil {
+			return 0, "", err
+		}
+		return addr, arrayOf("char", len(node.Value)+1), nil
+	case *PrefixExpression:
+		if node.Operator == "*" {
+			ptr, err := i.evalExpression(node.Right, env)
+			if err != nil {
+				return 0, "", err
+			}
+			if !isPointerType(ptr.Type) {
+				return 0, "", fmt.Errorf("cannot dereference non-pointer value of type %s", ptr.Type)
+			}
+			return ptr.Int, pointerElem(ptr.Type), nil
 		}
+	}
+	return 0, "", fmt.Errorf("expression is not assignable: %s", expr.String())
+}
 
-		env.Set(ident.Value, result)
-		return result, nil
+// evalMemberAddress returns the address and type of the struct or union member selected
+// by node. For s.x the struct is located with evalAddress; when s is not an lvalue, such
+// as a struct returned from a function, its value is first copied into temporary storage.
+// For p->x the struct is the one p points to.
+func (i *Interpreter) evalMemberAddress(node *MemberExpression, env *Environment) (int64, string, error) {
+	var base int64
+	var structType string
+
+	if node.Operator == "->" {
+		ptr, err := i.evalExpression(node.Left, env)
+		if err != nil {
+			return 0, "", err
+		}
+		if !isPointerType(ptr.Type) || !isStructType(pointerElem(ptr.Type)) {
+			return 0, "", fmt.Errorf("invalid type argument of '->' (have %s)", ptr.Type)
+		}
+		base, structType = ptr.Int, pointerElem(ptr.Type)
+	} else if isLValue(node.Left) {
+		addr, typ, err := i.evalAddress(node.Left, env)
+		if err != nil {
+			return 0, "", err
+		}
+		base, structType = addr, typ
+	} else {
+		val, err := i.evalExpression(node.Left, env)
+		if err != nil {
+			return 0, "", err
+		}
+		if !isStructType(val.Type) {
+			return 0, "", fmt.Errorf("request for member %s in something not a struct or union", node.Member)
+		}
+		addr, err := i.memory.alloc(i.sizeOf(val.Type), i.alignOf(val.Type))
+		if err != nil {
+			return 0, "", err
+		}
+		if err := i.store(addr, val.Type, val); err != nil {
+			return 0, "", err
+		}
+		base, structType = addr, val.Type
+	}
+
+	if !isStructType(structType) {
+		return 0, "", fmt.Errorf("request for member %s in something not a struct or union", node.Member)
+	}
+	layout, err := i.structLayout(structType)
+	if err != nil {
+		return 0, "", err
+	}
+	field, ok := layout.fields[node.Member]
+	if !ok {
+		return 0, "", fmt.Errorf("%s has no member named %s", structType, node.Member)
+	}
+
+	return base + int64(field.Offset), field.Type, nil
+}
+
+// isLValue reports whether expr designates an object in memory, as opposed to
+// producing a temporary value.
+func isLValue(expr Expression) bool {
+	switch node := expr.(type) {
+	case *Identifier, *ArrayExpression, *StringLiteral:
+		return true
+	case *PrefixExpression:
+		return node.Operator == "*"
+	case *MemberExpression:
+		return node.Operator == "->" || isLValue(node.Left)
+	}
+	return false
+}
+
+// addToLValue adds delta to the object designated by expr, as done by the ++ and --
+// operators; a pointer moves by delta elements. It returns the object's value before
+// and after the update.
+func (i *Interpreter) addToLValue(expr Expression, delta int64, env *Environment) (*Value, *Value, error) {
+	addr, typ, err := i.evalAddress(expr, env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oldValue, err := i.load(addr, typ)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newValue := &Value{Type: oldValue.Type, Int: oldValue.Int + delta, Float: oldValue.Float}
+	if isFloatType(oldValue.Type) {
+		newValue.Float += float64(delta)
+	} else if isPointerType(typ) {
+		newValue.Int = oldValue.Int + delta*i.elemSize(typ)
 	}
 
-	return nil, fmt.Errorf("invalid assignment target")
+	if err := i.store(addr, typ, newValue); err != nil {
+		return nil, nil, err
+	}
+	newValue, err = i.load(addr, typ)
+	return oldValue, newValue, err
+}
+
+// defines reports whether the program defines the function with the given name,
+// rather than only declaring it.
+func (i *Interpreter) defines(name string) bool {
+	fn, ok := i.functions[name]
+	return ok && fn.Body != nil
 }
 
 // evalCallExpression evaluates a function call expression within the interpreter.
-// It first determines the function name from the provided node. If the function is a built-in,
-// it invokes the corresponding built-in implementation. If the function is user-defined, it creates
-// a new environment, binds the arguments to the function parameters, and executes the function body.
+// It first determines the function name from the provided node. If the function is a built-in
+// that the program does not define itself, it invokes the corresponding built-in implementation;
+// a definition in the program takes precedence over a built-in of the same name, while a mere
+// prototype, such as the one for malloc in stdlib.h, does not. If the function is user-defined, it checks
+// the number of arguments, evaluates them, creates a new environment, binds each argument to its
+// parameter after converting it to the parameter's type, and executes the function body.
 // The function also preserves and restores the interpreter's return state to handle nested returns correctly.
 // Returns the result of the function call or an error if the function is undefined or evaluation fails.
 func (i *Interpreter) evalCallExpression(node *CallExpression, env *Environment) (*Value, error) {
@@ -726,29 +1704,60 @@ func (i *Interpreter) evalCallExpression(node *CallExpression, env *Environment)
 		return nil, fmt.Errorf("invalid function call")
 	}
 
-	// Check for built-in functions
-	if builtin, ok := i.builtins[funcName]; ok {
+	// Check for built-in functions the program does not define
+	if builtin, ok := i.builtins[funcName]; ok && !i.defines(funcName) {
 		return builtin(node.Arguments, env)
 	}
 
 	// Check for user-defined functions
 	if fn, ok := i.functions[funcName]; ok {
+		if fn.Body == nil {
+			return nil, fmt.Errorf("function %s is declared but never defined", funcName)
+		}
+
+		// A prototype fixes the number of arguments, apart from those matching "...";
+		// without one, extra arguments are evaluated and then ignored
+		switch {
+		case len(node.Arguments) < len(fn.Parameters):
+			return nil, fmt.Errorf("too few arguments to function %s", funcName)
+		case len(node.Arguments) > len(fn.Parameters) && fn.Prototyped && !fn.Variadic:
+			return nil, fmt.Errorf("too many arguments to function %s", funcName)
+		}
+
+		args := make([]*Value, len(node.Arguments))
+		for idx, arg := range node.Arguments {
+			val, err := i.evalExpression(arg, env)
+			if err != nil {
+				return nil, err
+			}
+			args[idx] = val
+		}
+
 		// Save current return state
 		savedShouldReturn := i.shouldReturn
 		savedReturnValue := i.returnValue
 
-		// Create new environment for function
+		// Create new environment for function; storage it allocates is
+		// released when the call returns
 		fnEnv := NewEnclosedEnvironment(i.globals)
+		mark := i.memory.mark()
+		defer i.memory.release(mark)
 
-		// Bind parameters
+		// Bind parameters, converting each argument to its parameter's type
 		for idx, param := range fn.Parameters {
-			if idx < len(node.Arguments) {
-				val, err := i.evalExpression(node.Arguments[idx], env)
-				if err != nil {
-					return nil, err
-				}
-				fnEnv.Set(param.Name, val)
+			val, ok := convertArgument(args[idx], param.Type)
+			if !ok {
+				return nil, fmt.Errorf("incompatible type for argument %d of %s: expected %s but argument is of type %s",
+					idx+1, funcName, param.Type, args[idx].Type)
+			}
+			addr, err := i.memory.alloc(i.sizeOf(param.Type), i.alignOf(param.Type))
+			if err != nil {
+				return nil, err
+			}
+			if err := i.store(addr, param.Type, val); err != nil {
+				return nil, err
 			}
+			fnEnv.Set(param.Name, &Variable{Type: param.Type, Addr: addr})
 		}
 
 		// Execute function body
@@ -758,12 +1767,88 @@ func (i *Interpreter) evalCallExpression(node *CallExpression, env *Environment)
 		i.shouldReturn = savedShouldReturn
 		i.returnValue = savedReturnValue
 
-		return result, err
+		if err != nil {
+			return nil, err
+		}
+		// The value returned is converted to the function's return type
+		if fn.ReturnType != "void" {
+			result = convert(result, fn.ReturnType)
+		}
+		return result, nil
 	}
 
 	return nil, fmt.Errorf("undefined function: %s", funcName)
 }
 
+// convertArgument converts a value passed to a function to the type of the parameter
+// it initializes, as if by assignment, so that passing 3 to a double parameter yields
+// 3.0 and passing 300 to a char parameter keeps its low byte. Reports false for a
+// value that cannot be assigned to the parameter, such as a struct passed for an int
+// or a floating-point value passed for a pointer.
+func convertArgument(val *Value, typ string) (*Value, bool) {
+	switch {
+	case isStructType(typ) || isStructType(val.Type):
+		if val.Type != typ {
+			return nil, false
+		}
+	case isPointerType(typ) && isFloatType(val.Type), isFloatType(typ) && isPointerType(val.Type):
+		return nil, false
+	}
+	return convert(val, typ), true
+}
+
+// evalCastExpression evaluates a cast by converting the value of its operand to the
+// named type. Conversions between integer and floating types follow the same rules as
+// assignment, so (char)(c + 1) keeps the low byte and (int)2.7 is 2. Pointers can be cast
+// to other pointer types and to and from integers, and a cast to void discards the value.
+// Returns an error for conversions C does not allow, such as casting to a struct type.
+func (i *Interpreter) evalCastExpression(node *CastExpression, env *Environment) (*Value, error) {
+	val, err := i.evalExpression(node.Right, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case node.Type == "void":
+		return &Value{Type: "void"}, nil
+	case isStructType(node.Type) || isArrayType(node.Type):
+		return nil, fmt.Errorf("conversion to non-scalar type %s requested", node.Type)
+	case isStructType(val.Type):
+		return nil, fmt.Errorf("cannot convert %s to %s", val.Type, node.Type)
+	case isPointerType(node.Type):
+		if isFloatType(val.Type) {
+			return nil, fmt.Errorf("cannot convert %s to %s", val.Type, node.Type)
+		}
+		return &Value{Type: node.Type, Int: val.Int}, nil
+	case isFloatType(node.Type) && isPointerType(val.Type):
+		return nil, fmt.Errorf("cannot convert %s to %s", val.Type, node.Type)
+	case isIntegerType(node.Type) || isFloatType(node.Type):
+		return convert(val, node.Type), nil
+	}
+	return nil, fmt.Errorf("cannot convert to type %s", node.Type)
+}
+
+// evalSizeofExpression returns the size in bytes of the type named by node, or of the
+// type of its operand, as an unsigned long. The operand is never evaluated, so
+// sizeof x++ leaves x unchanged. An array operand yields the size of the whole array,
+// and a struct includes its padding. Returns an error for types without a size,
+// such as void, functions or arrays declared without a length.
+func (i *Interpreter) evalSizeofExpression(node *SizeofExpression, env *Environment) (*Value, error) {
+	typ := node.Type
+	if node.Operand != nil {
+		var err error
+		if typ, err = i.typeOf(node.Operand, env); err != nil {
+			return nil, err
+		}
+	}
+
+	size := i.sizeOf(typ)
+	if size < 0 {
+		return nil, fmt.Errorf("invalid application of sizeof to incomplete type %s", typ)
+	}
+	return &Value{Type: "unsigned long", Int: int64(size)}, nil
+}
+
 // evalConditionalExpression evaluates a conditional (ternary) expression node within the interpreter.
 // It first evaluates the condition expression. If the condition is truthy, it evaluates and returns
 // the consequence expression; otherwise, it evaluates and returns the alternative expression.
@@ -781,7 +1866,7 @@ func (i *Interpreter) evalConditionalExpression(node *ConditionalExpression, env
 }
 
 func (i *Interpreter) isTruthy(val *Value) bool {
-	if val.Type == "float" {
+	if isFloatType(val.Type) {
 		return val.Float != 0.0
 	}
 	return val.Int != 0
@@ -802,6 +1887,7 @@ func boolToInt(b bool) int64 {
 //   - printf: Prints formatted output to stdout, similar to C's printf.
 //   - sleep: Pauses execution for a specified number of milliseconds.
 //   - putchar: Prints a single character to stdout.
+//   - malloc, calloc, realloc, free: Allocate, resize and release blocks of heap memory.
 //   - sqrt: Returns the square root of a number.
 //   - pow: Raises a number to the power of another.
 //   - sin, cos, tan: Trigonometric functions (sine, cosine, tangent).
@@ -824,26 +1910,29 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 
-		format := processEscapeSequences(formatVal.Str)
-		argVals := []interface{}{}
+		if !isPointerType(formatVal.Type) {
+			return nil, fmt.Errorf("printf: format must be a string, not %s", formatVal.Type)
+		}
+		format, err := i.memory.cString(formatVal.Int)
+		if err != nil {
+			return nil, err
+		}
+		argVals := []*Value{}
 
 		for idx := 1; idx < len(args); idx++ {
 			val, err := i.evalExpression(args[idx], env)
 			if err != nil {
 				return nil, err
 			}
-
-			if val.Type == "float" {
-				argVals = append(argVals, val.Float)
-			} else if val.Type == "string" {
-				argVals = append(argVals, val.Str)
-			} else {
-				argVals = append(argVals, val.Int)
-			}
+			argVals = append(argVals, val)
 		}
 
-		fmt.Printf(format, argVals...)
-		return &Value{Type: "int", Int: 0}, nil
+		out, err := i.formatPrintf(format, argVals)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Print(out)
+		return &Value{Type: "int", Int: int64(len(out))}, nil
 	}
 
 	// sleep - millisecond resolution
@@ -876,6 +1965,94 @@ func (i *Interpreter) registerBuiltins() {
 		return &Value{Type: "int", Int: val.Int}, nil
 	}
 
+	// Memory allocation
+
+	// malloc - allocate a block of zeroed heap memory
+	i.builtins["malloc"] = func(args []Expression, env *Environment) (*Value, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("malloc requires 1 argument")
+		}
+		size, err := i.evalExpression(args[0], env)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := i.memory.malloc(int(size.Int))
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Type: "void*", Int: addr}, nil
+	}
+
+	// calloc - allocate a zeroed array of n elements of the given size
+	i.builtins["calloc"] = func(args []Expression, env *Environment) (*Value, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("calloc requires 2 arguments")
+		}
+		n, err := i.evalExpression(args[0], env)
+		if err != nil {
+			return nil, err
+		}
+		size, err := i.evalExpression(args[1], env)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := i.memory.malloc(int(n.Int * size.Int))
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Type: "void*", Int: addr}, nil
+	}
+
+	// realloc - resize a heap block, moving its contents to a new block
+	i.builtins["realloc"] = func(args []Expression, env *Environment) (*Value, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("realloc requires 2 arguments")
+		}
+		ptr, err := i.evalExpression(args[0], env)
+		if err != nil {
+			return nil, err
+		}
+		size, err := i.evalExpression(args[1], env)
+		if err != nil {
+			return nil, err
+		}
+		oldSize := 0
+		if ptr.Int != 0 {
+			var ok bool
+			if oldSize, ok = i.memory.blockSize(ptr.Int); !ok {
+				return nil, fmt.Errorf("realloc: invalid pointer 0x%x", ptr.Int)
+			}
+		}
+		addr, err := i.memory.malloc(int(size.Int))
+		if err != nil {
+			return nil, err
+		}
+		if n := min(oldSize, int(size.Int)); n > 0 {
+			src, _ := i.memory.bytes(ptr.Int, n)
+			dst, _ := i.memory.bytes(addr, n)
+			copy(dst, src)
+		}
+		if err := i.memory.free(ptr.Int); err != nil {
+			return nil, err
+		}
+		return &Value{Type: "void*", Int: addr}, nil
+	}
+
+	// free - release a heap block
+	i.builtins["free"] = func(args []Expression, env *Environment) (*Value, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("free requires 1 argument")
+		}
+		ptr, err := i.evalExpression(args[0], env)
+		if err != nil {
+			return nil, err
+		}
+		if err := i.memory.free(ptr.Int); err != nil {
+			return nil, err
+		}
+		return &Value{Type: "void"}, nil
+	}
+
 	// Floating point math functions
 
 	// sqrt - square root
@@ -888,12 +2065,12 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Sqrt(f)}, nil
+		return &Value{Type: "double", Float: math.Sqrt(f)}, nil
 	}
 
 	// pow - power (x^y)
@@ -910,17 +2087,17 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var baseF, expF float64
-		if base.Type == "float" {
+		if isFloatType(base.Type) {
 			baseF = base.Float
 		} else {
 			baseF = float64(base.Int)
 		}
-		if exp.Type == "float" {
+		if isFloatType(exp.Type) {
 			expF = exp.Float
 		} else {
 			expF = float64(exp.Int)
 		}
-		return &Value{Type: "float", Float: math.Pow(baseF, expF)}, nil
+		return &Value{Type: "double", Float: math.Pow(baseF, expF)}, nil
 	}
 
 	// sin - sine
@@ -933,12 +2110,12 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Sin(f)}, nil
+		return &Value{Type: "double", Float: math.Sin(f)}, nil
 	}
 
 	// cos - cosine
@@ -951,12 +2128,12 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Cos(f)}, nil
+		return &Value{Type: "double", Float: math.Cos(f)}, nil
 	}
 
 	// tan - tangent
@@ -969,12 +2146,12 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Tan(f)}, nil
+		return &Value{Type: "double", Float: math.Tan(f)}, nil
 	}
 
 	// abs - absolute value
@@ -986,8 +2163,8 @@ func (i *Interpreter) registerBuiltins() {
 		if err != nil {
 			return nil, err
 		}
-		if val.Type == "float" {
-			return &Value{Type: "float", Float: math.Abs(val.Float)}, nil
+		if isFloatType(val.Type) {
+			return &Value{Type: "double", Float: math.Abs(val.Float)}, nil
 		}
 		if val.Int < 0 {
 			return &Value{Type: "int", Int: -val.Int}, nil
@@ -1005,12 +2182,12 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Floor(f)}, nil
+		return &Value{Type: "double", Float: math.Floor(f)}, nil
 	}
 
 	// ceil - round up
@@ -1023,12 +2200,12 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Ceil(f)}, nil
+		return &Value{Type: "double", Float: math.Ceil(f)}, nil
 	}
 
 	// log - natural logarithm
@@ -1041,12 +2218,12 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Log(f)}, nil
+		return &Value{Type: "double", Float: math.Log(f)}, nil
 	}
 
 	// log10 - logarithm base 10
@@ -1059,12 +2236,12 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Log10(f)}, nil
+		return &Value{Type: "double", Float: math.Log10(f)}, nil
 	}
 
 	// exp - exponential (e^x)
@@ -1077,48 +2254,11 @@ func (i *Interpreter) registerBuiltins() {
 			return nil, err
 		}
 		var f float64
-		if val.Type == "float" {
+		if isFloatType(val.Type) {
 			f = val.Float
 		} else {
 			f = float64(val.Int)
 		}
-		return &Value{Type: "float", Float: math.Exp(f)}, nil
-	}
-}
-
-
-// processEscapeSequences takes a string containing C-style escape sequences
-// (such as \n, \t, \r, \\, \", and \0) and returns a new string with those
-// sequences replaced by their corresponding characters. Unrecognized escape
-// sequences are left as-is (the backslash and following character are both
-// included in the result).
-func processEscapeSequences(s string) string {
-	result := ""
-	i := 0
-	for i < len(s) {
-		if s[i] == '\\' && i+1 < len(s) {
-			switch s[i+1] {
-			case 'n':
-				result += "\n"
-			case 't':
-				result += "\t"
-			case 'r':
-				result += "\r"
-			case '\\':
-				result += "\\"
-			case '"':
-				result += "\""
-			case '0':
-				result += "\x00"
-			default:
-				result += string(s[i])
-				result += string(s[i+1])
-			}
-			i += 2
-		} else {
-			result += string(s[i])
-			i++
-		}
+		return &Value{Type: "double", Float: math.Exp(f)}, nil
 	}
-	return result
 }
@@ -15,13 +15,17 @@ type Lexer struct {
 	ch           byte // current char
 	line         int
 	column       int
+
+	// lineStart is set at the start of each line of input and cleared once a
+	// token has been read from it, so that preprocessing directives can be found.
+	lineStart bool
 }
 
 
 // NewLexer creates and initializes a new Lexer instance for the given input string.
 // It sets the starting line and column, reads the first character, and returns a pointer to the Lexer.
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input, line: 1, column: 0}
+	l := &Lexer{input: input, line: 1, column: 0, lineStart: true}
 	l.readChar()
 	return l
 }
@@ -58,12 +62,22 @@ func (l *Lexer) peekChar() byte {
 // It skips whitespace and comments, then determines the type of token based on the current character.
 // The function handles single and multi-character operators, delimiters, string and character literals,
 // identifiers, numbers, and special tokens such as EOF and ILLEGAL. The returned Token includes
-// the token type, literal value, and the line and column where the token was found.
+// the token type, literal value, and the line and column where the token was found, and
+// records whether the token was preceded by white space and whether it begins a line.
 func (l *Lexer) NextToken() Token {
-	var tok Token
+	space := l.skipSpace()
+	lineStart := l.lineStart
+	l.lineStart = false
+
+	tok := l.scanToken()
+	tok.Space = space
+	tok.LineStart = lineStart
+	return tok
+}
 
-	l.skipWhitespace()
-	l.skipComments()
+// scanToken reads the token that starts at the current character.
+func (l *Lexer) scanToken() Token {
+	var tok Token
 
 	tok.Line = l.line
 	tok.Column = l.column
@@ -224,17 +238,48 @@ func (l *Lexer) NextToken() Token {
 	case ',':
 		tok = Token{Type: COMMA, Literal: string(l.ch), Line: tok.Line, Column: tok.Column}
 	case '.':
-		tok = Token{Type: DOT, Literal: string(l.ch), Line: tok.Line, Column: tok.Column}
+		if isDigit(l.peekChar()) {
+			// A floating constant such as .5
+			tok.Literal, tok.Type = l.readNumber()
+			return tok
+		}
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = Token{Type: ELLIPSIS, Literal: "...", Line: tok.Line, Column: tok.Column}
+		} else {
+			tok = Token{Type: DOT, Literal: string(l.ch), Line: tok.Line, Column: tok.Column}
+		}
+	case '#':
+		if l.peekChar() == '#' {
+			l.readChar()
+			tok = Token{Type: HASHHASH, Literal: "##", Line: tok.Line, Column: tok.Column}
+		} else {
+			tok = Token{Type: HASH, Literal: string(l.ch), Line: tok.Line, Column: tok.Column}
+		}
 	case '?':
 		tok = Token{Type: QUESTION, Literal: string(l.ch), Line: tok.Line, Column: tok.Column}
 	case ':':
 		tok = Token{Type: COLON, Literal: string(l.ch), Line: tok.Line, Column: tok.Column}
 	case '"':
+		start := l.position
 		tok.Type = STRING
 		tok.Literal = l.readString()
+		if l.ch != '"' {
+			// An unterminated literal ends with its line
+			tok.Raw = l.input[start:l.position]
+			return tok
+		}
+		tok.Raw = l.input[start : l.position+1]
 	case '\'':
+		start := l.position
 		tok.Type = CHAR
 		tok.Literal = l.readCharLiteral()
+		if l.ch != '\'' {
+			tok.Raw = l.input[start:l.position]
+			return tok
+		}
+		tok.Raw = l.input[start : l.position+1]
 	case 0:
 		tok.Literal = ""
 		tok.Type = EOF
@@ -255,43 +300,76 @@ func (l *Lexer) NextToken() Token {
 	return tok
 }
 
-// skipWhitespace advances the lexer position past any whitespace characters,
-// including spaces, tabs, newlines, and carriage returns.
-func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
-	}
-}
-
-// skipComments skips over single-line (//) and multi-line (/* ... */) comments in the input.
-// It advances the lexer position past any comments and any subsequent whitespace.
-// For multi-line comments, it also handles consecutive comments by recursively calling itself.
-func (l *Lexer) skipComments() {
-	if l.ch == '/' {
-		if l.peekChar() == '/' {
+// skipSpace advances the lexer past any white space and comments, including
+// single-line (//) and multi-line (/* ... */) comments, and reports whether it
+// skipped anything. Each newline sets lineStart, except one preceded by a backslash,
+// which joins the two lines into one as in C.
+func (l *Lexer) skipSpace() bool {
+	start := l.position
+	for {
+		switch {
+		case l.ch == '\n':
+			l.lineStart = true
+			l.readChar()
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\r' || l.ch == '\f' || l.ch == '\v':
+			l.readChar()
+		case l.ch == '\\' && (l.peekChar() == '\n' || l.peekChar() == '\r'):
+			l.readChar()
+			if l.ch == '\r' && l.peekChar() == '\n' {
+				l.readChar()
+			}
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
 			// Single line comment
 			for l.ch != '\n' && l.ch != 0 {
 				l.readChar()
 			}
-			l.skipWhitespace()
-		} else if l.peekChar() == '*' {
+		case l.ch == '/' && l.peekChar() == '*':
 			// Multi-line comment
 			l.readChar()
 			l.readChar()
-			for {
-				if l.ch == 0 {
-					break
-				}
-				if l.ch == '*' && l.peekChar() == '/' {
-					l.readChar()
-					l.readChar()
-					break
-				}
+			for l.ch != 0 && !(l.ch == '*' && l.peekChar() == '/') {
+				l.readChar()
+			}
+			if l.ch != 0 {
 				l.readChar()
+				l.readChar()
+			}
+		default:
+			return l.position > start
+		}
+	}
+}
+
+// atLineEnd reports whether nothing but white space and comments is left on the
+// current line, without consuming any input.
+func (l *Lexer) atLineEnd() bool {
+	probe := *l
+	probe.lineStart = false
+	probe.skipSpace()
+	return probe.lineStart || probe.ch == 0
+}
+
+// skipText skips the text of lines that the preprocessor is leaving out, up to the
+// next line whose first character, apart from white space and comments, is '#'.
+// The text is not split into tokens, so an apostrophe in it does not begin a
+// character literal, but comments and continued lines are honored as in C.
+func (l *Lexer) skipText() {
+	for l.ch != 0 {
+		if l.lineStart {
+			l.skipSpace()
+			if l.ch == '#' {
+				return
 			}
-			l.skipWhitespace()
-			l.skipComments() // Handle consecutive comments
+			l.lineStart = false
+			continue
+		}
+		if l.ch == '\n' || (l.ch == '\\' && (l.peekChar() == '\n' || l.peekChar() == '\r')) ||
+			(l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')) {
+			l.skipSpace()
+			continue
 		}
+		l.readChar()
 	}
 }
 
@@ -307,13 +385,27 @@ func (l *Lexer) readIdentifier() string {
 }
 
 // readNumber reads a numeric literal from the input and determines its type.
-// It supports integer, floating-point, and scientific notation formats, as well as
-// optional suffixes such as L, U, and F (case-insensitive) commonly used in C-like languages.
+// It supports decimal, octal (0755) and hexadecimal (0xFF) integers, floating-point and
+// scientific notation formats, and any suffix letters that follow, such as the U, L and F
+// (case-insensitive) suffixes of C. The suffix is kept in the returned text for the
+// parser to check and to determine the type of the constant from.
 // The function returns the string representation of the number and its corresponding TokenType.
 func (l *Lexer) readNumber() (string, TokenType) {
 	position := l.position
 	tokType := INT
 
+	if l.ch == '0' && (l.peekChar() == 'x' || l.peekChar() == 'X') {
+		l.readChar()
+		l.readChar()
+		for isHexDigit(l.ch) {
+			l.readChar()
+		}
+		for isLetter(l.ch) || isDigit(l.ch) {
+			l.readChar()
+		}
+		return l.input[position:l.position], tokType
+	}
+
 	for isDigit(l.ch) {
 		l.readChar()
 	}
@@ -337,50 +429,92 @@ func (l *Lexer) readNumber() (string, TokenType) {
 		}
 	}
 
-	// Skip suffixes like L, U, F
-	if l.ch == 'L' || l.ch == 'l' || l.ch == 'U' || l.ch == 'u' || l.ch == 'F' || l.ch == 'f' {
+	// Suffixes like U, L and F
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
-		if l.ch == 'L' || l.ch == 'l' || l.ch == 'U' || l.ch == 'u' {
-			l.readChar()
-		}
 	}
 
 	return l.input[position:l.position], tokType
 }
 
-// readString reads a string literal from the input, handling escape sequences.
+// readString reads a string literal from the input, decoding escape sequences.
 // It assumes the opening quote has already been encountered and advances until
 // it finds the closing quote or the end of input. The function returns the
-// string content without the surrounding quotes.
+// characters the string contains, without the surrounding quotes.
 func (l *Lexer) readString() string {
-	position := l.position + 1
+	return l.readQuoted('"')
+}
+
+// readCharLiteral reads a character literal from the input, decoding escape sequences.
+// It assumes the current position is at the opening single quote and reads until the closing single quote or end of input.
+// Returns the characters of the literal (excluding the surrounding single quotes); '\n' yields a single newline.
+func (l *Lexer) readCharLiteral() string {
+	return l.readQuoted('\'')
+}
+
+// readQuoted reads the characters of a string or character literal up to the closing
+// quote, replacing each escape sequence with the character it stands for. A literal
+// left unterminated stops at the end of its line, leaving the lexer on the newline.
+func (l *Lexer) readQuoted(quote byte) string {
+	var out []byte
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == quote || l.ch == 0 || l.ch == '\n' {
 			break
 		}
 		if l.ch == '\\' {
 			l.readChar()
+			out = append(out, l.readEscape())
+			continue
 		}
+		out = append(out, l.ch)
 	}
-	return l.input[position:l.position]
+	return string(out)
 }
 
-// readCharLiteral reads a character literal from the input, handling escape sequences.
-// It assumes the current position is at the opening single quote and reads until the closing single quote or end of input.
-// Returns the string representing the character literal (excluding the surrounding single quotes).
-func (l *Lexer) readCharLiteral() string {
-	position := l.position + 1
-	for {
-		l.readChar()
-		if l.ch == '\'' || l.ch == 0 {
-			break
+// readEscape decodes the escape sequence whose first character, following the
+// backslash, is the current character, and returns the character it denotes. It
+// understands the simple escapes such as \n and \t, octal escapes of one to three
+// digits such as \0 and \101, and hexadecimal escapes such as \x41. The lexer is
+// left on the last character of the sequence. An unknown escape stands for the
+// character after the backslash.
+func (l *Lexer) readEscape() byte {
+	switch l.ch {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case 'a':
+		return '\a'
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	case 'v':
+		return '\v'
+	case 'x':
+		if !isHexDigit(l.peekChar()) {
+			return l.ch
 		}
-		if l.ch == '\\' {
+		var n byte
+		for isHexDigit(l.peekChar()) {
 			l.readChar()
+			n = n*16 + hexValue(l.ch)
 		}
+		return n
 	}
-	return l.input[position:l.position]
+
+	if l.ch >= '0' && l.ch <= '7' {
+		n := l.ch - '0'
+		for count := 1; count < 3 && l.peekChar() >= '0' && l.peekChar() <= '7'; count++ {
+			l.readChar()
+			n = n*8 + l.ch - '0'
+		}
+		return n
+	}
+	return l.ch
 }
 
 // isLetter checks if the given byte represents a Unicode letter or an underscore ('_').
@@ -393,3 +527,19 @@ func isLetter(ch byte) bool {
 func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
+
+// isHexDigit reports whether ch is a hexadecimal digit: 0-9, a-f or A-F.
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
+// hexValue returns the value of the hexadecimal digit ch.
+func hexValue(ch byte) byte {
+	switch {
+	case isDigit(ch):
+		return ch - '0'
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10
+	}
+	return ch - 'A' + 10
+}
@@ -0,0 +1,379 @@
+package cint
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+)
+
+const (
+	// staticBase is the address of the first byte of the static segment. Keeping it
+	// well above zero means an address of 0 never refers to a valid object.
+	staticBase int64 = 0x1000
+
+	// heapBase is the address of the first byte of the heap segment.
+	heapBase int64 = 0x40000000
+
+	// stackBase is the address of the first byte of the stack segment.
+	stackBase int64 = 0x100000000
+
+	// maxSegmentSize bounds each segment so that runaway recursion or allocation is
+	// reported as an error rather than exhausting host memory.
+	maxSegmentSize = 64 << 20
+)
+
+// segment is a contiguous range of interpreter memory starting at base.
+type segment struct {
+	base int64
+	data []byte
+}
+
+// alloc reserves size bytes of zeroed storage aligned to align bytes at the end of
+// the segment and returns the address of the first byte.
+func (s *segment) alloc(size, align int) (int64, bool) {
+	if align < 1 {
+		align = 1
+	}
+	start := (len(s.data) + align - 1) / align * align
+	end := start + size
+	if size < 0 || end > maxSegmentSize {
+		return 0, false
+	}
+
+	if end > cap(s.data) {
+		grown := make([]byte, end, 2*end)
+		copy(grown, s.data)
+		s.data = grown
+	} else {
+		s.data = s.data[:end]
+	}
+	clear(s.data[start:end])
+
+	return s.base + int64(start), true
+}
+
+// Memory is the byte-addressed store that backs every variable of an interpreted program.
+// Automatic objects are allocated on a stack that grows as storage is requested and shrinks
+// back to a saved mark when a function returns, mirroring automatic storage in C. Objects
+// that live for the whole run, such as the contents of string literals, are allocated in
+// a separate static segment that is never released. Blocks obtained from malloc live in
+// a heap segment; freeing a block makes it inaccessible and its space free for later
+// blocks to reuse.
+type Memory struct {
+	static segment
+	heap   segment
+	stack  segment
+
+	// blocks records the size of each live heap block by address, and starts
+	// holds the same addresses in ascending order for lookups by address.
+	blocks map[int64]int
+	starts []int64
+
+	// holes lists the free ranges of the heap left by freed blocks in ascending
+	// order of address. Adjacent holes are merged, and a hole at the end of the
+	// heap is given back to the segment.
+	holes []hole
+}
+
+// hole is a free range of size bytes of the heap starting at addr.
+type hole struct {
+	addr int64
+	size int
+}
+
+// heapAlign is the alignment of every heap block, enough for any type. Blocks take
+// up a multiple of it, so that every hole is aligned too.
+const heapAlign = 16
+
+// NewMemory creates and returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{
+		static: segment{base: staticBase},
+		heap:   segment{base: heapBase},
+		stack:  segment{base: stackBase, data: make([]byte, 0, 4096)},
+		blocks: make(map[int64]int),
+	}
+}
+
+// alloc reserves size bytes of zeroed stack storage aligned to align bytes and returns
+// the address of the first byte. It returns an error if the stack would grow beyond
+// its maximum size.
+func (m *Memory) alloc(size, align int) (int64, error) {
+	addr, ok := m.stack.alloc(size, align)
+	if !ok {
+		if size < 0 {
+			return 0, fmt.Errorf("cannot allocate object of unknown size")
+		}
+		return 0, fmt.Errorf("stack overflow")
+	}
+	return addr, nil
+}
+
+// allocStatic reserves size bytes of zeroed storage in the static segment and returns
+// the address of the first byte. Static storage is never released.
+func (m *Memory) allocStatic(size, align int) (int64, error) {
+	addr, ok := m.static.alloc(size, align)
+	if !ok {
+		return 0, fmt.Errorf("out of static memory")
+	}
+	return addr, nil
+}
+
+// malloc reserves a zeroed heap block of size bytes, aligned for any type, and
+// returns its address. The first hole large enough is reused; otherwise the heap
+// grows. A request for zero bytes still returns a unique address.
+func (m *Memory) malloc(size int) (int64, error) {
+	if size < 0 {
+		return 0, fmt.Errorf("malloc: invalid size %d", size)
+	}
+	if size > maxSegmentSize {
+		return 0, fmt.Errorf("out of heap memory")
+	}
+	footprint := blockFootprint(size)
+
+	var addr int64
+	idx := slices.IndexFunc(m.holes, func(h hole) bool { return h.size >= footprint })
+	if idx >= 0 {
+		h := &m.holes[idx]
+		addr = h.addr
+		h.addr += int64(footprint)
+		h.size -= footprint
+		if h.size == 0 {
+			m.holes = slices.Delete(m.holes, idx, idx+1)
+		}
+		offset := addr - m.heap.base
+		clear(m.heap.data[offset : offset+int64(footprint)])
+	} else {
+		var ok bool
+		if addr, ok = m.heap.alloc(footprint, heapAlign); !ok {
+			return 0, fmt.Errorf("out of heap memory")
+		}
+	}
+
+	m.blocks[addr] = size
+	idx, _ = slices.BinarySearch(m.starts, addr)
+	m.starts = slices.Insert(m.starts, idx, addr)
+	return addr, nil
+}
+
+// free releases the heap block at addr, turning its space into a hole that is
+// merged with any holes next to it. Freeing a null pointer does nothing.
+// Returns an error if addr is not the address of a live heap block.
+func (m *Memory) free(addr int64) error {
+	if addr == 0 {
+		return nil
+	}
+	size, ok := m.blocks[addr]
+	if !ok {
+		return fmt.Errorf("free: invalid pointer 0x%x", addr)
+	}
+	delete(m.blocks, addr)
+	idx, _ := slices.BinarySearch(m.starts, addr)
+	m.starts = slices.Delete(m.starts, idx, idx+1)
+
+	freed := hole{addr: addr, size: blockFootprint(size)}
+	idx, _ = slices.BinarySearchFunc(m.holes, addr, func(h hole, addr int64) int {
+		return cmp.Compare(h.addr, addr)
+	})
+	if idx < len(m.holes) && freed.addr+int64(freed.size) == m.holes[idx].addr {
+		freed.size += m.holes[idx].size
+		m.holes = slices.Delete(m.holes, idx, idx+1)
+	}
+	if idx > 0 && m.holes[idx-1].addr+int64(m.holes[idx-1].size) == freed.addr {
+		idx--
+		freed.addr = m.holes[idx].addr
+		freed.size += m.holes[idx].size
+		m.holes = slices.Delete(m.holes, idx, idx+1)
+	}
+
+	// Space at the end of the heap goes back to the segment
+	if freed.addr+int64(freed.size) == m.heap.base+int64(len(m.heap.data)) {
+		m.heap.data = m.heap.data[:freed.addr-m.heap.base]
+		return nil
+	}
+	m.holes = slices.Insert(m.holes, idx, freed)
+	return nil
+}
+
+// blockFootprint returns the number of bytes of the heap taken up by a block of
+// size bytes.
+func blockFootprint(size int) int {
+	return (max(size, 1) + heapAlign - 1) / heapAlign * heapAlign
+}
+
+// blockSize returns the size of the live heap block at addr.
+func (m *Memory) blockSize(addr int64) (int, bool) {
+	size, ok := m.blocks[addr]
+	return size, ok
+}
+
+// mark returns the current top of the stack so that it can later be passed to release.
+func (m *Memory) mark() int {
+	return len(m.stack.data)
+}
+
+// release frees all stack storage allocated since the given mark was taken.
+func (m *Memory) release(mark int) {
+	m.stack.data = m.stack.data[:mark]
+}
+
+// bytes returns the n bytes of memory starting at addr. The returned slice aliases
+// the underlying storage, so writes to it modify memory. An error is returned if
+// any part of the range lies outside allocated storage.
+func (m *Memory) bytes(addr int64, n int) ([]byte, error) {
+	if addr == 0 {
+		return nil, fmt.Errorf("null pointer dereference")
+	}
+
+	seg := &m.static
+	if addr >= stackBase {
+		seg = &m.stack
+	} else if addr >= heapBase {
+		seg = &m.heap
+		if !m.inBlock(addr, n) {
+			return nil, fmt.Errorf("invalid memory access at address 0x%x", addr)
+		}
+	}
+	offset := addr - seg.base
+	if offset < 0 || offset+int64(n) > int64(len(seg.data)) {
+		return nil, fmt.Errorf("invalid memory access at address 0x%x", addr)
+	}
+	return seg.data[offset : offset+int64(n)], nil
+}
+
+// inBlock reports whether the n bytes starting at addr lie within a single live heap block.
+func (m *Memory) inBlock(addr int64, n int) bool {
+	idx, found := slices.BinarySearch(m.starts, addr)
+	if !found {
+		if idx == 0 {
+			return false
+		}
+		idx--
+	}
+	start := m.starts[idx]
+	return addr+int64(n) <= start+int64(m.blocks[start])
+}
+
+// cString reads the NUL-terminated string of chars starting at addr.
+func (m *Memory) cString(addr int64) (string, error) {
+	var out []byte
+	for {
+		b, err := m.bytes(addr+int64(len(out)), 1)
+		if err != nil {
+			return "", err
+		}
+		if b[0] == 0 {
+			return string(out), nil
+		}
+		out = append(out, b[0])
+	}
+}
+
+// load reads a value of the given type from memory at addr.
+// Integers are sign- or zero-extended to int64 according to their type, and a
+// float is widened from its four-byte representation to the float64 held in Value.
+// A struct or union is returned as a copy of its bytes held in Ptr.
+func (i *Interpreter) load(addr int64, typ string) (*Value, error) {
+	size := i.sizeOf(typ)
+	if size <= 0 {
+		return nil, fmt.Errorf("cannot load value of type %s", typ)
+	}
+
+	b, err := i.memory.bytes(addr, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if isStructType(typ) {
+		return &Value{Type: typ, Ptr: append([]byte(nil), b...)}, nil
+	}
+
+	if typ == "float" {
+		return &Value{Type: typ, Float: float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))}, nil
+	}
+	if typ == "double" {
+		return &Value{Type: typ, Float: math.Float64frombits(binary.LittleEndian.Uint64(b))}, nil
+	}
+
+	var n int64
+	switch size {
+	case 1:
+		n = int64(b[0])
+	case 2:
+		n = int64(binary.LittleEndian.Uint16(b))
+	case 4:
+		n = int64(binary.LittleEndian.Uint32(b))
+	default:
+		n = int64(binary.LittleEndian.Uint64(b))
+	}
+	return &Value{Type: typ, Int: convertInt(n, typ)}, nil
+}
+
+// store writes val into memory at addr as a value of the given type,
+// converting between integer and floating-point representations as needed.
+// Structs and unions are copied byte for byte from the value's Ptr.
+// Integers wider than the destination are truncated.
+func (i *Interpreter) store(addr int64, typ string, val *Value) error {
+	size := i.sizeOf(typ)
+	if size <= 0 {
+		return fmt.Errorf("cannot store value of type %s", typ)
+	}
+
+	b, err := i.memory.bytes(addr, size)
+	if err != nil {
+		return err
+	}
+
+	if isStructType(typ) {
+		data, ok := val.Ptr.([]byte)
+		if !ok || val.Type != typ {
+			return fmt.Errorf("incompatible types when assigning to type %s from type %s", typ, val.Type)
+		}
+		copy(b, data)
+		return nil
+	}
+
+	if isFloatType(typ) {
+		f := floatValue(val)
+		if typ == "float" {
+			binary.LittleEndian.PutUint32(b, math.Float32bits(float32(f)))
+		} else {
+			binary.LittleEndian.PutUint64(b, math.Float64bits(f))
+		}
+		return nil
+	}
+
+	n := intValue(val, typ)
+	switch size {
+	case 1:
+		b[0] = byte(n)
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(n))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(n))
+	default:
+		binary.LittleEndian.PutUint64(b, uint64(n))
+	}
+	return nil
+}
+
+// internString returns the address of a NUL-terminated copy of s in static memory,
+// which is the char array a string literal denotes. Each distinct string is copied
+// only once, so identical literals share their storage, as C permits.
+func (i *Interpreter) internString(s string) (int64, error) {
+	if addr, ok := i.strings[s]; ok {
+		return addr, nil
+	}
+
+	addr, err := i.memory.allocStatic(len(s)+1, 1)
+	if err != nil {
+		return 0, err
+	}
+	b, _ := i.memory.bytes(addr, len(s))
+	copy(b, s)
+
+	i.strings[s] = addr
+	return addr, nil
+}
@@ -3,6 +3,7 @@ package cint
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 
@@ -10,17 +11,76 @@ import (
 // It maintains the current and next tokens, a reference to the lexer,
 // and a list of parsing errors encountered during processing.
 type Parser struct {
-	l         *Lexer
+	l         TokenSource
 	curToken  Token
 	peekToken Token
 	errors    []string
+
+	// pendingDecls holds struct and union definitions found while parsing a type,
+	// until the enclosing declaration is complete and can emit them.
+	pendingDecls []Statement
+	anonCount    int
+
+	// scopes is a stack of symbol tables, innermost last, recording what each
+	// ordinary identifier refers to. It lets the parser tell a typedef name
+	// from a variable of the same spelling.
+	scopes []map[string]symbol
+
+	// labels and gotos record the labels defined and the goto statements seen in
+	// the function being parsed, so that every goto can be matched to its label.
+	labels map[string]*LabelStatement
+	gotos  []*GotoStatement
+
+	// switches holds the case labels seen so far in each switch statement being
+	// parsed, innermost last.
+	switches []*switchLabels
+
+	// functions records the most complete declaration seen so far of each function,
+	// so that later prototypes and the definition can be checked against it.
+	functions map[string]*FunctionDecl
+
+	// structs records the struct and union definitions seen so far by type name,
+	// so that sizeof in a constant expression can lay them out.
+	structs map[string]*StructDecl
 }
 
+// symbolKind classifies what an ordinary identifier has been declared as.
+type symbolKind int
 
-// NewParser creates and returns a new Parser instance using the provided Lexer.
+const (
+	symVariable  symbolKind = iota // a variable, parameter or function
+	symTypedef                     // a name introduced by typedef
+	symEnumConst                   // an enumerator
+)
+
+// symbol records the declaration of an ordinary identifier. Variables and typedef
+// names carry their type, and enumerators carry their integer value.
+type symbol struct {
+	kind  symbolKind
+	typ   string
+	value int64
+}
+
+
+// switchLabels records the case labels of a switch statement: the type that case
+// values are converted to, which is the promoted type of the controlling expression,
+// and for each converted value the token of the case label that used it.
+type switchLabels struct {
+	typ   string
+	cases map[int64]Token
+}
+
+// TokenSource supplies the tokens that a Parser reads. Both Lexer and Preprocessor
+// implement it.
+type TokenSource interface {
+	NextToken() Token
+}
+
+// NewParser creates and returns a new Parser instance reading tokens from l.
 // It initializes the parser by advancing the lexer twice to set up the current and peek tokens.
-func NewParser(l *Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+func NewParser(l TokenSource) *Parser {
+	p := &Parser{l: l, errors: []string{}, functions: make(map[string]*FunctionDecl), structs: make(map[string]*StructDecl)}
+	p.pushScope()
 	p.nextToken()
 	p.nextToken()
 	return p
@@ -61,13 +121,52 @@ func (p *Parser) expectPeek(t TokenType) bool {
 
 // peekError records an error message when the next token does not match the expected TokenType.
 // It appends a formatted error message to the parser's error list, including details about the
-// expected and actual token types, their string representations, the literal value, and the line number.
+// expected and actual token types, their string representations, the literal value, and the position.
 func (p *Parser) peekError(t TokenType) {
-	msg := fmt.Sprintf("expected next token to be %v (type %d), got %v (type %d) '%s' instead at line %d",
-		t, t, p.peekToken.Type, p.peekToken.Type, p.peekToken.Literal, p.peekToken.Line)
+	msg := fmt.Sprintf("expected next token to be %v (type %d), got %v (type %d) '%s' instead at %s",
+		t, t, p.peekToken.Type, p.peekToken.Type, p.peekToken.Literal, p.peekToken.Pos())
 	p.errors = append(p.errors, msg)
 }
 
+// pushScope opens a new innermost scope for identifiers, as at the start of a block.
+func (p *Parser) pushScope() {
+	p.scopes = append(p.scopes, make(map[string]symbol))
+}
+
+// popScope discards the innermost scope and every identifier declared in it.
+func (p *Parser) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+// declare records the meaning of name in the innermost scope, hiding any
+// declaration of the same name in an enclosing scope.
+func (p *Parser) declare(name string, sym symbol) {
+	p.scopes[len(p.scopes)-1][name] = sym
+}
+
+// lookup returns the innermost visible declaration of name.
+func (p *Parser) lookup(name string) (symbol, bool) {
+	for idx := len(p.scopes) - 1; idx >= 0; idx-- {
+		if sym, ok := p.scopes[idx][name]; ok {
+			return sym, true
+		}
+	}
+	return symbol{}, false
+}
+
+// isTypeName reports whether tok begins a type: either a type keyword or an
+// identifier currently declared as a typedef name.
+func (p *Parser) isTypeName(tok Token) bool {
+	if p.isTypeKeyword(tok.Type) {
+		return true
+	}
+	if tok.Type == IDENT {
+		sym, ok := p.lookup(tok.Literal)
+		return ok && sym.kind == symTypedef
+	}
+	return false
+}
+
 // Errors returns a slice of error messages encountered during parsing.
 func (p *Parser) Errors() []string {
 	return p.errors
@@ -75,15 +174,28 @@ func (p *Parser) Errors() []string {
 
 
 // ParseProgram parses the entire input and constructs a Program AST node.
-// It iterates through all tokens until EOF, parsing each statement and
-// appending it to the Program's Statements slice. Returns the fully
-// constructed Program node.
+// It iterates through all tokens until EOF, parsing each declaration and
+// appending it to the Program's Statements slice. A name followed by '('
+// at file scope begins a function whose return type defaults to int; any
+// other statement outside a function is reported as an error.
+// Returns the fully constructed Program node.
 func (p *Parser) ParseProgram() *Program {
 	program := &Program{}
 	program.Statements = []Statement{}
 
 	for !p.curTokenIs(EOF) {
-		stmt := p.parseStatement()
+		var stmt Statement
+		if p.curTokenIs(IDENT) && p.peekTokenIs(LPAREN) {
+			// A function defined without a return type, such as main() { ... }, returns int
+			if fn := p.parseFunctionDecl("int", p.curToken.Literal, p.curToken); fn != nil {
+				stmt = fn
+			}
+		} else if p.isTypeName(p.curToken) || p.isStorageClass(p.curToken.Type) || p.curTokenIs(TYPEDEF) {
+			stmt = p.parseStatement()
+		} else if !p.curTokenIs(SEMICOLON) {
+			p.errors = append(p.errors, fmt.Sprintf("expected declaration, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+			p.parseStatement()
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -95,90 +207,537 @@ func (p *Parser) ParseProgram() *Program {
 
 // parseStatement parses the current token and returns the corresponding Statement node.
 // It determines the type of statement based on the current token, handling declarations,
-// control flow statements (return, if, while, for, break, continue), block statements,
+// control flow statements (return, if, while, do-while, for, switch, break, continue, goto), labels, block statements,
 // and expression statements. The method delegates parsing to specialized functions
 // depending on the token type.
 func (p *Parser) parseStatement() Statement {
-	// Check for type keywords (variable or function declaration)
-	if p.isTypeKeyword(p.curToken.Type) {
+	// Check for type names and storage classes (variable or function declaration)
+	if p.isTypeName(p.curToken) || p.isStorageClass(p.curToken.Type) {
 		return p.parseDeclaration()
 	}
 
 	switch p.curToken.Type {
+	case TYPEDEF:
+		return p.parseTypedef()
 	case RETURN:
 		return p.parseReturnStatement()
 	case IF:
 		return p.parseIfStatement()
 	case WHILE:
 		return p.parseWhileStatement()
+	case DO:
+		return p.parseDoWhileStatement()
 	case FOR:
 		return p.parseForStatement()
+	case SWITCH:
+		return p.parseSwitchStatement()
+	case CASE, DEFAULT:
+		return p.parseCaseStatement()
 	case BREAK:
 		return p.parseBreakStatement()
 	case CONTINUE:
 		return p.parseContinueStatement()
+	case GOTO:
+		return p.parseGotoStatement()
 	case LBRACE:
 		return p.parseBlockStatement()
+	case IDENT:
+		if p.peekTokenIs(COLON) {
+			return p.parseLabelStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 // isTypeKeyword checks if the given TokenType represents a C type keyword,
-// such as int, char, float, double, void, long, short, unsigned, or signed.
+// such as int, char, float, double, void, long, short, unsigned, or signed,
+// or begins a struct, union or enum type.
 func (p *Parser) isTypeKeyword(t TokenType) bool {
 	return t == INT_KW || t == CHAR_KW || t == FLOAT_KW || t == DOUBLE ||
-		t == VOID || t == LONG || t == SHORT || t == UNSIGNED || t == SIGNED
+		t == VOID || t == LONG || t == SHORT || t == UNSIGNED || t == SIGNED ||
+		t == STRUCT || t == UNION || t == ENUM
 }
 
-// parseType parses and returns a type name from the current token stream.
-// It starts with the current token's literal value and appends a '*' for each
-// subsequent pointer indicator (STAR token) encountered, advancing the token
-// stream as it goes. The resulting string represents the parsed type,
-// including any pointer indirection.
-func (p *Parser) parseType() string {
-	typ := p.curToken.Literal
-	p.nextToken()
+// isStorageClass checks if the given TokenType is a storage-class specifier: static,
+// extern, auto or register.
+func (p *Parser) isStorageClass(t TokenType) bool {
+	return t == STATIC || t == EXTERN || t == AUTO || t == REGISTER
+}
 
-	// Handle pointer types
+// parseStorageClass parses the storage-class specifier, if any, that begins a
+// declaration and returns its keyword, or "" if there is none. Returns false after
+// recording an error if more than one is given, or if auto or register is used
+// at file scope.
+func (p *Parser) parseStorageClass() (string, bool) {
+	storage := ""
+	for p.isStorageClass(p.curToken.Type) {
+		if storage != "" {
+			p.errors = append(p.errors, fmt.Sprintf("multiple storage classes in declaration at %s", p.curToken.Pos()))
+			return "", false
+		}
+		storage = p.curToken.Literal
+		if len(p.scopes) == 1 && (p.curTokenIs(AUTO) || p.curTokenIs(REGISTER)) {
+			p.errors = append(p.errors, fmt.Sprintf("file-scope declaration specifies %s at %s", storage, p.curToken.Pos()))
+			return "", false
+		}
+		p.nextToken()
+	}
+	return storage, true
+}
+
+// parseTypeName parses a type name as written in a cast or in sizeof, such as
+// "unsigned long", "char *" or "int (*)[3]": a type specifier followed by a
+// declarator that names nothing. Returns false after recording an error if the
+// declarator is malformed or declares a name.
+func (p *Parser) parseTypeName() (string, bool) {
+	name, typ, ok := p.parseDeclarator(p.parseTypeSpecifier())
+	if ok && name.Literal != "" {
+		p.errors = append(p.errors, fmt.Sprintf("unexpected name %s in type name at %s", name.Literal, name.Pos()))
+		return "", false
+	}
+	return typ, ok
+}
+
+// parseTypeSpecifier parses the type specifier that begins a declaration: a run of
+// arithmetic type keywords, a typedef name, or a struct, union or enum type. Pointer
+// stars that follow are left for the caller, since in a declaration such as
+// "char *p, c;" they belong to the individual declarator rather than to the type.
+func (p *Parser) parseTypeSpecifier() string {
+	if p.curTokenIs(STRUCT) || p.curTokenIs(UNION) {
+		return p.parseStructType()
+	}
+	if p.curTokenIs(ENUM) {
+		return p.parseEnumType()
+	}
+	if sym, ok := p.lookup(p.curToken.Literal); ok && sym.kind == symTypedef && p.curTokenIs(IDENT) {
+		p.nextToken()
+		return sym.typ
+	}
+	return p.parseBasicType()
+}
+
+// parsePointers appends a '*' to typ for each pointer indicator (STAR token) at the
+// current position and returns the resulting pointer type.
+func (p *Parser) parsePointers(typ string) string {
 	for p.curTokenIs(STAR) {
 		typ += "*"
 		p.nextToken()
 	}
+	return typ
+}
 
+// parseDeclarator parses the declarator that follows a type specifier, such as "*p",
+// "m[3][4]" or "(*pa)[3]", and returns the name it declares together with its type,
+// derived from base. A declarator in parentheses binds more tightly than the array
+// dimensions after it, so "int (*pa)[3]" declares a pointer to an array of 3 ints
+// where "int *pa[3]" declares an array of 3 pointers. The name may be left out, as
+// in a type name or an unnamed parameter, in which case the returned token has an
+// empty literal. Returns false after recording an error if the declarator is
+// malformed or declares a function pointer, which is not supported.
+func (p *Parser) parseDeclarator(base string) (Token, string, bool) {
+	name, derive, ok := p.parseDerivation()
+	if !ok {
+		return Token{}, "", false
+	}
+	return name, derive(base), true
+}
+
+// parseDerivation parses a declarator for parseDeclarator. It returns the name the
+// declarator declares and a function that applies the declarator to the type it is
+// declared with: first its pointers, then its array dimensions, and finally the
+// declarator it encloses in parentheses, if any.
+func (p *Parser) parseDerivation() (Token, func(string) string, bool) {
+	stars := 0
+	for p.curTokenIs(STAR) {
+		stars++
+		p.nextToken()
+	}
+
+	var name Token
+	nested := func(typ string) string { return typ }
+	switch {
+	case p.curTokenIs(LPAREN) && (p.peekTokenIs(STAR) || p.peekTokenIs(LPAREN) || p.peekTokenIs(IDENT) && !p.isTypeName(p.peekToken)):
+		p.nextToken()
+		var ok bool
+		if name, nested, ok = p.parseDerivation(); !ok {
+			return Token{}, nil, false
+		}
+		if !p.curTokenIs(RPAREN) {
+			p.errors = append(p.errors, fmt.Sprintf("expected ')' in declarator, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+			return Token{}, nil, false
+		}
+		p.nextToken()
+	case p.curTokenIs(IDENT):
+		name = p.curToken
+		p.nextToken()
+	}
+
+	if p.curTokenIs(LPAREN) {
+		p.errors = append(p.errors, fmt.Sprintf("pointers to functions are not supported at %s", p.curToken.Pos()))
+		return Token{}, nil, false
+	}
+	dims := p.parseArrayDims()
+	if dims == nil {
+		return Token{}, nil, false
+	}
+
+	return name, func(typ string) string {
+		typ += strings.Repeat("*", stars)
+		for d := len(dims) - 1; d >= 0; d-- {
+			typ = arrayOf(typ, dims[d])
+		}
+		return nested(typ)
+	}, true
+}
+
+// parseBasicType parses a run of arithmetic type keywords such as "unsigned char",
+// "long int" or "short" and returns the canonical name of the type they spell:
+// one of the names in integerTypes, "float", "double" or "void". "signed" and "int"
+// may be omitted or added freely, as in C, and "long long" means the same as "long".
+// Records an error and returns "int" for an invalid combination.
+func (p *Parser) parseBasicType() string {
+	startToken := p.curToken
+	counts := make(map[TokenType]int)
+	for p.curTokenIs(INT_KW) || p.curTokenIs(CHAR_KW) || p.curTokenIs(SHORT) || p.curTokenIs(LONG) ||
+		p.curTokenIs(SIGNED) || p.curTokenIs(UNSIGNED) || p.curTokenIs(FLOAT_KW) || p.curTokenIs(DOUBLE) ||
+		p.curTokenIs(VOID) {
+		counts[p.curToken.Type]++
+		p.nextToken()
+	}
+	if len(counts) == 0 {
+		p.nextToken()
+		return startToken.Literal
+	}
+
+	invalid := func() string {
+		p.errors = append(p.errors, fmt.Sprintf("invalid combination of type specifiers at %s", startToken.Pos()))
+		return "int"
+	}
+	for t, n := range counts {
+		if n > 1 && !(t == LONG && n == 2) {
+			return invalid()
+		}
+	}
+	if counts[SIGNED] > 0 && counts[UNSIGNED] > 0 {
+		return invalid()
+	}
+	sign := counts[SIGNED] + counts[UNSIGNED]
+	size := counts[SHORT] + counts[LONG]
+
+	switch {
+	case counts[VOID] > 0:
+		if len(counts) > 1 {
+			return invalid()
+		}
+		return "void"
+	case counts[FLOAT_KW] > 0:
+		if len(counts) > 1 {
+			return invalid()
+		}
+		return "float"
+	case counts[DOUBLE] > 0:
+		if sign > 0 || counts[SHORT] > 0 || counts[INT_KW] > 0 || counts[CHAR_KW] > 0 || counts[LONG] > 1 {
+			return invalid()
+		}
+		return "double"
+	case counts[CHAR_KW] > 0:
+		if size > 0 || counts[INT_KW] > 0 {
+			return invalid()
+		}
+		if counts[UNSIGNED] > 0 {
+			return "unsigned char"
+		}
+		return "char"
+	case counts[SHORT] > 0 && counts[LONG] > 0:
+		return invalid()
+	}
+
+	typ := "int"
+	if counts[SHORT] > 0 {
+		typ = "short"
+	} else if counts[LONG] > 0 {
+		typ = "long"
+	}
+	if counts[UNSIGNED] > 0 {
+		typ = "unsigned " + typ
+	}
 	return typ
 }
 
 // parseDeclaration parses a declaration statement in the source code.
-// It first attempts to parse a type, then checks if the next token is an identifier.
-// If the identifier is followed by a left parenthesis, it is treated as a function declaration
-// and delegated to parseFunctionDecl. Otherwise, it is treated as a variable declaration
-// and delegated to parseVarDecl. Returns a Statement representing the parsed declaration,
-// or nil if the declaration is invalid.
+// It first parses an optional storage class and the type specifier, then one or more
+// comma-separated declarators,
+// each with its own pointer stars and array dimensions. If the first identifier is followed by
+// a left parenthesis, it is treated as a function declaration and delegated to
+// parseFunctionDecl. Otherwise each declarator is a variable declaration delegated to
+// parseVarDecl, so that "char *p, c;" declares a char* and a char. Struct and union
+// definitions encountered in the type are emitted ahead of the declaration itself, and
+// a definition followed directly by a semicolon stands alone. Returns a Statement
+// representing the parsed declaration, a DeclarationList when it declares several
+// names, or nil if the declaration is invalid or declares nothing that needs storage.
+//
+// As the program is a single translation unit, static and extern only matter inside
+// a function: there a static variable is given static storage, and an extern one
+// refers to the variable defined at file scope. An extern declaration at file scope
+// without an initializer defines nothing, and auto and register are ignored.
 func (p *Parser) parseDeclaration() Statement {
-	typ := p.parseType()
+	startToken := p.curToken
+	storage, ok := p.parseStorageClass()
+	if !ok {
+		return nil
+	}
+	if !p.isTypeName(p.curToken) {
+		p.errors = append(p.errors, fmt.Sprintf("expected type after %s, got '%s' at %s", storage, p.curToken.Literal, p.curToken.Pos()))
+		return nil
+	}
+	baseType := p.parseTypeSpecifier()
+	decls := p.pendingDecls
+	p.pendingDecls = nil
+
+	if p.curTokenIs(SEMICOLON) {
+		// A declaration such as "struct node;" declares only a tag
+		if len(decls) == 0 {
+			return nil
+		}
+		return declarationList(startToken, decls)
+	}
+
+	for {
+		typ := p.parsePointers(baseType)
+		if p.curTokenIs(IDENT) && p.peekTokenIs(LPAREN) {
+			// Check if it's a function declaration (peek ahead)
+			// Don't consume identifier yet, let parseFunctionDecl handle it
+			fn := p.parseFunctionDecl(typ, p.curToken.Literal, p.curToken)
+			if fn == nil {
+				return nil
+			}
+			return declarationList(startToken, append(decls, fn))
+		}
+
+		// It's a variable declaration
+		nameToken, typ, ok := p.parseDeclarator(typ)
+		if !ok {
+			return nil
+		}
+		if nameToken.Literal == "" {
+			p.errors = append(p.errors, fmt.Sprintf("expected identifier in declaration, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+			return nil
+		}
+		vd := p.parseVarDecl(typ, nameToken.Literal, nameToken)
+		if vd == nil {
+			return nil
+		}
+		switch {
+		case storage == "extern" && vd.Value != nil && len(p.scopes) > 1:
+			p.errors = append(p.errors, fmt.Sprintf("%s has both extern and initializer at %s", vd.Name, vd.Token.Pos()))
+			return nil
+		case storage == "extern" && vd.Value == nil && len(p.scopes) == 1:
+			// Only declares a variable defined elsewhere in the file
+		case (storage == "static" || storage == "extern") && len(p.scopes) > 1:
+			vd.Storage = storage
+			decls = append(decls, vd)
+		default:
+			decls = append(decls, vd)
+		}
+
+		if !p.curTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
+	}
 
-	if !p.curTokenIs(IDENT) {
+	if !p.curTokenIs(SEMICOLON) {
+		p.errors = append(p.errors, fmt.Sprintf("expected ';' after declaration, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+		return nil
+	}
+	if len(decls) == 0 {
 		return nil
 	}
+	return declarationList(startToken, decls)
+}
 
-	name := p.curToken.Literal
-	nameToken := p.curToken
+// declarationList wraps the given statements in a DeclarationList, or returns the
+// statement itself when there is only one.
+func declarationList(token Token, decls []Statement) Statement {
+	if len(decls) == 1 {
+		return decls[0]
+	}
+	return &DeclarationList{Token: token, Declarations: decls}
+}
 
-	// Check if it's a function declaration (peek ahead)
-	if p.peekTokenIs(LPAREN) {
-		// Don't consume identifier yet, let parseFunctionDecl handle it
-		return p.parseFunctionDecl(typ, name, nameToken)
+// parseStructType parses a struct or union type specifier starting at the struct or
+// union keyword. It accepts a tag, a member list in braces, or both, and returns the
+// type string such as "struct point". A member list produces a StructDecl that is
+// queued in pendingDecls for the enclosing declaration to emit. The parser is left
+// on the token following the specifier.
+func (p *Parser) parseStructType() string {
+	decl := &StructDecl{Token: p.curToken, Kind: p.curToken.Literal}
+	p.nextToken()
+
+	if p.curTokenIs(IDENT) {
+		decl.Name = p.curToken.Literal
+		p.nextToken()
+	} else if p.curTokenIs(LBRACE) {
+		p.anonCount++
+		decl.Name = fmt.Sprintf("__anon%d", p.anonCount)
+	} else {
+		p.errors = append(p.errors, fmt.Sprintf("expected %s tag or member list at %s", decl.Kind, p.curToken.Pos()))
+		return decl.TypeName()
+	}
+
+	if p.curTokenIs(LBRACE) {
+		decl.Fields = p.parseStructFields()
+		p.pendingDecls = append(p.pendingDecls, decl)
+		p.structs[decl.TypeName()] = decl
+		p.nextToken() // consume }
+	}
+
+	return decl.TypeName()
+}
+
+// parseTypedef parses a typedef declaration starting at the typedef keyword, such as
+// "typedef unsigned long size_t;" or "typedef struct node *NodePtr, Node;". Each name is
+// declared in the current scope as standing for its type, with its own pointer stars and
+// array dimensions applied to the base type. Typedefs produce no statement of their own,
+// but a struct or union defined in the base type is returned so that it is registered.
+func (p *Parser) parseTypedef() Statement {
+	startToken := p.curToken
+	p.nextToken() // consume typedef
+
+	if !p.isTypeName(p.curToken) {
+		p.errors = append(p.errors, fmt.Sprintf("expected type after typedef, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+		return nil
+	}
+	baseType := p.parseTypeSpecifier()
+	typeDecls := p.pendingDecls
+	p.pendingDecls = nil
+
+	for {
+		name, typ, ok := p.parseDeclarator(baseType)
+		if !ok {
+			return nil
+		}
+		if name.Literal == "" {
+			p.errors = append(p.errors, fmt.Sprintf("expected typedef name, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+			return nil
+		}
+		p.declare(name.Literal, symbol{kind: symTypedef, typ: typ})
+
+		if !p.curTokenIs(COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
-	// It's a variable declaration
-	p.nextToken() // consume the identifier
-	return p.parseVarDecl(typ, name, nameToken)
+	if !p.curTokenIs(SEMICOLON) {
+		p.errors = append(p.errors, fmt.Sprintf("expected ';' after typedef, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+		return nil
+	}
+
+	if len(typeDecls) == 0 {
+		return nil
+	}
+	return declarationList(startToken, typeDecls)
+}
+
+// parseEnumType parses an enum type specifier starting at the enum keyword, such as
+// "enum color { RED, GREEN = 5, BLUE }". Each enumerator is declared in the current scope;
+// one without an explicit value is one greater than the previous enumerator, starting
+// at zero. Enumerated types are represented as int, which is returned as the type.
+// The parser is left on the token following the specifier.
+func (p *Parser) parseEnumType() string {
+	p.nextToken() // consume enum
+
+	if p.curTokenIs(IDENT) {
+		p.nextToken() // the tag is not needed; every enum is an int
+	}
+	if !p.curTokenIs(LBRACE) {
+		return "int"
+	}
+	p.nextToken()
+
+	var next int64
+	for !p.curTokenIs(RBRACE) && !p.curTokenIs(EOF) {
+		if !p.curTokenIs(IDENT) {
+			p.errors = append(p.errors, fmt.Sprintf("expected enumerator name, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+			return "int"
+		}
+		name := p.curToken.Literal
+
+		if p.peekTokenIs(ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			val, ok := p.constantValue(p.parseExpression(COMMA_PREC))
+			if !ok {
+				p.errors = append(p.errors, fmt.Sprintf("enumerator value for %s is not an integer constant at %s", name, p.curToken.Pos()))
+				return "int"
+			}
+			next = val
+		}
+		p.declare(name, symbol{kind: symEnumConst, value: next})
+		next++
+		p.nextToken()
+
+		if p.curTokenIs(COMMA) {
+			p.nextToken()
+		} else if !p.curTokenIs(RBRACE) {
+			p.errors = append(p.errors, fmt.Sprintf("expected ',' or '}' after enumerator, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+			return "int"
+		}
+	}
+	p.nextToken() // consume }
+
+	return "int"
+}
+
+// parseStructFields parses the member declarations of a struct or union body, starting
+// at the opening brace and leaving the parser on the closing brace. Each member
+// declaration has a type followed by one or more comma-separated names, each with
+// optional pointer stars and array dimensions.
+func (p *Parser) parseStructFields() []*StructField {
+	fields := []*StructField{}
+	p.nextToken()
+
+	for !p.curTokenIs(RBRACE) && !p.curTokenIs(EOF) {
+		if !p.isTypeName(p.curToken) {
+			p.errors = append(p.errors, fmt.Sprintf("expected member declaration, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+			return fields
+		}
+		baseType := p.parseTypeSpecifier()
+
+		for {
+			name, fieldType, ok := p.parseDeclarator(baseType)
+			if !ok {
+				return fields
+			}
+			if name.Literal == "" {
+				p.errors = append(p.errors, fmt.Sprintf("expected member name, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+				return fields
+			}
+			fields = append(fields, &StructField{Type: fieldType, Name: name.Literal})
+
+			if !p.curTokenIs(COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+
+		if !p.curTokenIs(SEMICOLON) {
+			p.errors = append(p.errors, fmt.Sprintf("expected ';' after member declaration at %s", p.curToken.Pos()))
+			return fields
+		}
+		p.nextToken()
+	}
+
+	return fields
 }
 
 // parseFunctionDecl parses a function declaration starting from the given return type, function name, and token.
 // It expects the next token to be a left parenthesis '(', followed by zero or more parameter declarations,
-// and a closing right parenthesis ')'. Each parameter consists of a type and an optional name.
+// and a closing right parenthesis ')'. Each parameter consists of a type and an optional name. An old-style
+// definition instead lists the parameter names alone and declares their types before the body.
 // After the parameter list, it checks for either a function body (enclosed in braces) or a semicolon
 // indicating a function prototype. Returns a pointer to the constructed FunctionDecl, or nil if parsing fails.
 func (p *Parser) parseFunctionDecl(returnType, name string, token Token) *FunctionDecl {
@@ -196,27 +755,58 @@ func (p *Parser) parseFunctionDecl(returnType, name string, token Token) *Functi
 		return nil
 	}
 
+	// Parameters are visible only within the function
+	p.declare(name, symbol{kind: symVariable})
+	p.pushScope()
+	defer p.popScope()
+
 	// Now at (, move to next token
 	p.nextToken()
 
 	// Parse parameters
-	if !p.curTokenIs(RPAREN) {
+	if p.curTokenIs(IDENT) && !p.isTypeName(p.curToken) {
+		// An old-style definition lists only the parameter names
+		for p.curTokenIs(IDENT) {
+			fn.Parameters = append(fn.Parameters, &Parameter{Name: p.curToken.Literal})
+			p.declare(p.curToken.Literal, symbol{kind: symVariable, typ: "int"})
+			p.nextToken()
+			if !p.curTokenIs(COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+	} else if p.curTokenIs(VOID) && p.peekTokenIs(RPAREN) {
+		// (void) declares that the function takes no parameters
+		fn.Prototyped = true
+		p.nextToken()
+	} else if !p.curTokenIs(RPAREN) {
+		fn.Prototyped = true
 		for {
-			if !p.isTypeKeyword(p.curToken.Type) {
+			if p.curTokenIs(ELLIPSIS) && len(fn.Parameters) > 0 {
+				fn.Variadic = true
+				p.nextToken()
+				break
+			}
+			if !p.isTypeName(p.curToken) {
 				break
 			}
 
-			paramType := p.parseType()
-			paramName := ""
-			if p.curTokenIs(IDENT) {
-				paramName = p.curToken.Literal
-				p.nextToken()
+			name, paramType, ok := p.parseDeclarator(p.parseTypeSpecifier())
+			if !ok {
+				return nil
 			}
+			paramName := name.Literal
+
+			// An array parameter is really a pointer to the array's first element
+			paramType = decayType(paramType)
 
 			fn.Parameters = append(fn.Parameters, &Parameter{
 				Type: paramType,
 				Name: paramName,
 			})
+			if paramName != "" {
+				p.declare(paramName, symbol{kind: symVariable, typ: paramType})
+			}
 
 			if !p.curTokenIs(COMMA) {
 				break
@@ -234,22 +824,158 @@ func (p *Parser) parseFunctionDecl(returnType, name string, token Token) *Functi
 	// Move past )
 	p.nextToken()
 
+	// An old-style definition declares its parameters between ) and the body;
+	// any it leaves undeclared are int
+	if p.isTypeName(p.curToken) && !p.parseParameterDecls(fn) {
+		return nil
+	}
+	for _, param := range fn.Parameters {
+		if param.Type == "" {
+			param.Type = "int"
+		}
+	}
+
 	// Check for function body or just declaration
 	if p.curTokenIs(LBRACE) {
+		p.labels = make(map[string]*LabelStatement)
+		p.gotos = nil
 		fn.Body = p.parseBlockStatement()
+		for _, g := range p.gotos {
+			if g.Target = p.labels[g.Label]; g.Target == nil {
+				p.errors = append(p.errors, fmt.Sprintf("label %s used but not defined at %s", g.Label, g.Token.Pos()))
+			}
+		}
+		p.labels = nil
+		p.gotos = nil
 	} else if p.curTokenIs(SEMICOLON) {
 		// Function declaration only
 	}
 
+	if !p.declareFunction(fn) {
+		return nil
+	}
 	return fn
 }
 
-// parseVarDecl parses a variable declaration statement, including optional array
-// notation and initialization. It constructs and returns a VarDecl node with the
-// provided type, name, and token. If the declaration includes array brackets,
-// the type is modified to indicate an array. If an assignment is present, the
-// initialization expression is parsed and attached. The function also ensures
-// the statement is properly terminated with a semicolon.
+// declareFunction reconciles fn with any earlier declaration of a function of the same
+// name. The return types must agree, and so must the parameter types unless one of the
+// two is a declaration such as int f(); that says nothing about its parameters. A
+// function may be defined only once. When a definition follows a prototype, or the
+// other way round, the definition is marked as prototyped so that its calls are checked.
+// Returns false after recording an error if the declarations conflict.
+func (p *Parser) declareFunction(fn *FunctionDecl) bool {
+	prev, ok := p.functions[fn.Name]
+	if !ok {
+		p.functions[fn.Name] = fn
+		return true
+	}
+
+	if prev.Body != nil && fn.Body != nil {
+		p.errors = append(p.errors, fmt.Sprintf("redefinition of function %s at %s", fn.Name, fn.Token.Pos()))
+		return false
+	}
+	if !compatibleFunctions(prev, fn) {
+		p.errors = append(p.errors, fmt.Sprintf("conflicting types for function %s at %s", fn.Name, fn.Token.Pos()))
+		return false
+	}
+
+	// Keep the definition if there is one, otherwise the declaration that says most
+	prototyped := prev.Prototyped || fn.Prototyped
+	if fn.Body != nil || (prev.Body == nil && fn.Prototyped) {
+		p.functions[fn.Name] = fn
+	}
+	p.functions[fn.Name].Prototyped = prototyped
+	return true
+}
+
+// compatibleFunctions reports whether two declarations of the same function agree
+// on its return type and, where both describe them, on its parameters.
+func compatibleFunctions(a, b *FunctionDecl) bool {
+	if a.ReturnType != b.ReturnType {
+		return false
+	}
+	// A declaration without a prototype leaves the parameters unspecified, but a
+	// definition without one still has parameters
+	if (!a.Prototyped && a.Body == nil) || (!b.Prototyped && b.Body == nil) {
+		return true
+	}
+	if len(a.Parameters) != len(b.Parameters) || a.Variadic != b.Variadic {
+		return false
+	}
+	for idx, param := range a.Parameters {
+		if param.Type != b.Parameters[idx].Type {
+			return false
+		}
+	}
+	return true
+}
+
+// parseParameterDecls parses the parameter declarations of an old-style (K&R) function
+// definition, such as "int a, b; char *s;" in "int f(a, b, s) int a, b; char *s; {",
+// giving each named parameter of fn its type. Array parameters become pointers, as they
+// do in a prototype. The parser is left on the token following the last declaration.
+// Returns false after recording an error if a declaration is malformed or names
+// something that is not a parameter.
+func (p *Parser) parseParameterDecls(fn *FunctionDecl) bool {
+	for p.isTypeName(p.curToken) {
+		baseType := p.parseTypeSpecifier()
+
+		for {
+			nameToken, typ, ok := p.parseDeclarator(baseType)
+			if !ok {
+				return false
+			}
+			if nameToken.Literal == "" {
+				p.errors = append(p.errors, fmt.Sprintf("expected parameter name, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+				return false
+			}
+
+			var param *Parameter
+			for _, candidate := range fn.Parameters {
+				if candidate.Name == nameToken.Literal {
+					param = candidate
+				}
+			}
+			if param == nil {
+				p.errors = append(p.errors, fmt.Sprintf("declaration for parameter %s but no such parameter at %s", nameToken.Literal, nameToken.Pos()))
+				return false
+			}
+			if param.Type != "" {
+				p.errors = append(p.errors, fmt.Sprintf("redefinition of parameter %s at %s", nameToken.Literal, nameToken.Pos()))
+				return false
+			}
+			param.Type = decayType(typ)
+			p.declare(param.Name, symbol{kind: symVariable, typ: param.Type})
+
+			if !p.curTokenIs(COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+
+		if !p.curTokenIs(SEMICOLON) {
+			p.errors = append(p.errors, fmt.Sprintf("expected ';' after parameter declaration, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+			return false
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(LBRACE) {
+		p.errors = append(p.errors, fmt.Sprintf("expected function body after parameter declarations at %s", p.curToken.Pos()))
+		return false
+	}
+	return true
+}
+
+// parseVarDecl parses the optional initialization of a variable declaration, starting
+// just after its declarator. It constructs and returns a VarDecl node with the provided
+// type, name, and token; the type already includes the declarator's array dimensions,
+// so that "int m[3][4]" is recorded with type "int[3][4]".
+// If an assignment is present, the initialization expression or brace-enclosed
+// initializer list is parsed and attached; an array declared without a size, as in
+// "int a[] = {1, 2, 3}", keeps its unknown size until the interpreter counts the
+// initializers. The parser is left on the token following the declarator, which
+// is a comma or the terminating semicolon.
 //
 // Parameters:
 //   typ   - the type of the variable being declared
@@ -264,39 +990,107 @@ func (p *Parser) parseVarDecl(typ, name string, token Token) *VarDecl {
 		Type:  typ,
 		Name:  name,
 	}
+	p.declare(name, symbol{kind: symVariable, typ: typ})
 
-	// Check for array declaration
-	if p.curTokenIs(LBRACKET) {
+	// Check for initialization
+	if p.curTokenIs(ASSIGN) {
+		p.nextToken()
+		if p.curTokenIs(LBRACE) {
+			list := p.parseInitializerList()
+			if list == nil {
+				return nil
+			}
+			vd.Value = list
+		} else {
+			vd.Value = p.parseExpression(COMMA_PREC)
+		}
 		p.nextToken()
-		if !p.curTokenIs(RBRACKET) {
-			p.nextToken() // skip size for now
+	}
+
+	// Record the complete type for sizeof in later constant expressions
+	if _, n := arrayElem(typ); isArrayType(typ) && n < 0 {
+		i, env := p.typeContext()
+		typ, _ = i.declaredType(vd, env)
+	}
+	p.declare(name, symbol{kind: symVariable, typ: typ})
+
+	return vd
+}
+
+// parseArrayDims parses a sequence of array dimensions such as "[3][4]" starting at
+// the current token, leaving the parser on the token that follows the last ']'.
+// Each dimension must be a constant expression; an empty dimension "[]" is
+// returned as -1. It returns an empty slice if the current token is not '[', and
+// nil after recording an error if a dimension is malformed.
+func (p *Parser) parseArrayDims() []int {
+	dims := []int{}
+
+	for p.curTokenIs(LBRACKET) {
+		size := -1
+		if !p.peekTokenIs(RBRACKET) {
+			p.nextToken()
+			sizeExpr := p.parseExpression(LOWEST)
+			n, ok := p.constantValue(sizeExpr)
+			if !ok || n < 0 {
+				p.errors = append(p.errors, fmt.Sprintf("array size must be a non-negative constant expression at %s", p.curToken.Pos()))
+				return nil
+			}
+			size = int(n)
+		}
+		if !p.expectPeek(RBRACKET) {
+			return nil
 		}
 		p.nextToken() // consume ]
-		vd.Type += "[]"
+		dims = append(dims, size)
 	}
 
-	// Check for initialization
-	if p.curTokenIs(ASSIGN) {
+	return dims
+}
+
+// parseInitializerList parses a brace-enclosed initializer list such as "{1, 2, 3}"
+// starting at the opening brace. Elements may be expressions or nested initializer
+// lists, as in "{{1, 2}, {3, 4}}" or "{{0, 0}, "origin"}", and a trailing comma
+// before the closing brace is permitted. The parser is left on the closing brace.
+// Returns nil if the closing brace is missing.
+func (p *Parser) parseInitializerList() *InitializerList {
+	list := &InitializerList{Token: p.curToken, Elements: []Expression{}}
+
+	for !p.peekTokenIs(RBRACE) {
 		p.nextToken()
-		vd.Value = p.parseExpression(LOWEST)
-	}
+		if p.curTokenIs(LBRACE) {
+			elem := p.parseInitializerList()
+			if elem == nil {
+				return nil
+			}
+			list.Elements = append(list.Elements, elem)
+		} else {
+			list.Elements = append(list.Elements, p.parseExpression(COMMA_PREC))
+		}
 
-	if p.curTokenIs(SEMICOLON) {
-		// Already at semicolon, good
-	} else if p.peekTokenIs(SEMICOLON) {
+		if !p.peekTokenIs(COMMA) {
+			break
+		}
 		p.nextToken()
 	}
 
-	return vd
+	if !p.expectPeek(RBRACE) {
+		return nil
+	}
+
+	return list
 }
 
 // parseBlockStatement parses a block statement, which is a series of statements enclosed by braces.
 // It advances the parser to the next token, collects all statements until it encounters a closing brace (RBRACE)
 // or the end of file (EOF), and returns a BlockStatement containing the parsed statements.
+// Identifiers declared inside the block go out of scope at its end.
 func (p *Parser) parseBlockStatement() *BlockStatement {
 	block := &BlockStatement{Token: p.curToken}
 	block.Statements = []Statement{}
 
+	p.pushScope()
+	defer p.popScope()
+
 	p.nextToken()
 
 	for !p.curTokenIs(RBRACE) && !p.curTokenIs(EOF) {
@@ -398,6 +1192,41 @@ func (p *Parser) parseWhileStatement() *WhileStatement {
 	return stmt
 }
 
+// parseDoWhileStatement parses a 'do' statement from the current token stream.
+// It expects the following syntax: 'do { body } while (condition);'.
+// Returns a pointer to a DoWhileStatement node, or nil if the expected tokens
+// are not found in the correct order.
+func (p *Parser) parseDoWhileStatement() *DoWhileStatement {
+	stmt := &DoWhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseBlockStatement()
+
+	if !p.expectPeek(WHILE) {
+		return nil
+	}
+
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(SEMICOLON) {
+		return nil
+	}
+
+	return stmt
+}
+
 // parseForStatement parses a 'for' statement from the current token stream.
 // It expects the following syntax: for (init; condition; post) { body }.
 // The function parses the initialization statement, condition expression, and post expression,
@@ -406,6 +1235,10 @@ func (p *Parser) parseWhileStatement() *WhileStatement {
 func (p *Parser) parseForStatement() *ForStatement {
 	stmt := &ForStatement{Token: p.curToken}
 
+	// A declaration in the initialization is scoped to the loop
+	p.pushScope()
+	defer p.popScope()
+
 	if !p.expectPeek(LPAREN) {
 		return nil
 	}
@@ -450,6 +1283,79 @@ func (p *Parser) parseForStatement() *ForStatement {
 	return stmt
 }
 
+// parseSwitchStatement parses a 'switch' statement from the current token stream.
+// It expects the following syntax: 'switch (expression) { body }', where the body
+// contains 'case' and 'default' labels among its statements.
+// Returns a pointer to a SwitchStatement AST node, or nil if parsing fails.
+func (p *Parser) parseSwitchStatement() *SwitchStatement {
+	stmt := &SwitchStatement{Token: p.curToken}
+
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(LBRACE) {
+		return nil
+	}
+
+	// Case values are compared in the promoted type of the controlling expression
+	labels := &switchLabels{typ: "int", cases: make(map[int64]Token)}
+	i, env := p.typeContext()
+	if typ, err := i.typeOf(stmt.Value, env); err == nil && isIntegerType(typ) {
+		labels.typ = promote(typ)
+	}
+
+	p.switches = append(p.switches, labels)
+	stmt.Body = p.parseBlockStatement()
+	p.switches = p.switches[:len(p.switches)-1]
+
+	return stmt
+}
+
+// parseCaseStatement parses a 'case constant:' or 'default:' label, which must appear
+// within a switch statement. The value of a 'case' label must be an integer constant
+// expression, such as a number, a character or an enumerator, and no two labels of a
+// switch may have the same value once converted to the promoted type of the switch's
+// controlling expression. Returns nil if the label is malformed.
+func (p *Parser) parseCaseStatement() *CaseStatement {
+	stmt := &CaseStatement{Token: p.curToken}
+
+	if len(p.switches) == 0 {
+		p.errors = append(p.errors, fmt.Sprintf("'%s' label not within a switch statement at %s", stmt.Token.Literal, stmt.Token.Pos()))
+		return nil
+	}
+	labels := p.switches[len(p.switches)-1]
+
+	if p.curTokenIs(CASE) {
+		p.nextToken()
+		stmt.Value = p.parseExpression(LOWEST)
+		val, ok := p.constantValue(stmt.Value)
+		if !ok {
+			p.errors = append(p.errors, fmt.Sprintf("case label does not reduce to an integer constant at %s", stmt.Token.Pos()))
+			return nil
+		}
+		val = convertInt(val, labels.typ)
+		if prev, ok := labels.cases[val]; ok {
+			p.errors = append(p.errors, fmt.Sprintf("duplicate case value %d at %s, previously used at %s", val, stmt.Token.Pos(), prev.Pos()))
+		} else {
+			labels.cases[val] = stmt.Token
+		}
+	}
+
+	if !p.expectPeek(COLON) {
+		return nil
+	}
+
+	return stmt
+}
+
 // parseBreakStatement parses a 'break' statement from the current token stream.
 // It creates and returns a BreakStatement node. If the next token is a semicolon,
 // it advances the parser to the next token to consume it.
@@ -461,6 +1367,43 @@ func (p *Parser) parseBreakStatement() *BreakStatement {
 	return stmt
 }
 
+// parseGotoStatement parses a 'goto label;' statement from the current token stream.
+// The label is checked once the whole function body has been parsed, since a goto
+// may jump forward to a label defined later. Returns nil if no label name follows.
+func (p *Parser) parseGotoStatement() *GotoStatement {
+	stmt := &GotoStatement{Token: p.curToken}
+
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Label = p.curToken.Literal
+	p.gotos = append(p.gotos, stmt)
+
+	if p.peekTokenIs(SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseLabelStatement parses a 'name:' label. Labels may only appear inside a
+// function body, and each label name may be defined only once per function.
+func (p *Parser) parseLabelStatement() *LabelStatement {
+	stmt := &LabelStatement{Token: p.curToken, Name: p.curToken.Literal}
+	p.nextToken() // consume the name; now at :
+
+	if p.labels == nil {
+		p.errors = append(p.errors, fmt.Sprintf("label %s outside of a function at %s", stmt.Name, stmt.Token.Pos()))
+		return nil
+	}
+	if prev, ok := p.labels[stmt.Name]; ok {
+		p.errors = append(p.errors, fmt.Sprintf("duplicate label %s at %s, previously defined at %s", stmt.Name, stmt.Token.Pos(), prev.Token.Pos()))
+		return nil
+	}
+	p.labels[stmt.Name] = stmt
+
+	return stmt
+}
+
 // parseContinueStatement parses a 'continue' statement from the current token stream.
 // It creates and returns a ContinueStatement node. If the next token is a semicolon,
 // it advances the parser to the next token before returning the statement.
@@ -491,6 +1434,7 @@ func (p *Parser) parseExpressionStatement() *ExpressionStatement {
 const (
 	_ int = iota
 	LOWEST
+	COMMA_PREC  // ,
 	ASSIGN_PREC // =
 	CONDITIONAL // ?:
 	LOGOR       // ||
@@ -510,12 +1454,18 @@ const (
 )
 
 var precedences = map[TokenType]int{
+	COMMA:     COMMA_PREC,
 	ASSIGN:    ASSIGN_PREC,
 	PLUSEQ:    ASSIGN_PREC,
 	MINUSEQ:   ASSIGN_PREC,
 	STAREQ:    ASSIGN_PREC,
 	SLASHEQ:   ASSIGN_PREC,
 	PERCENTEQ: ASSIGN_PREC,
+	ANDEQ:     ASSIGN_PREC,
+	OREQ:      ASSIGN_PREC,
+	XOREQ:     ASSIGN_PREC,
+	LSHIFTEQ:  ASSIGN_PREC,
+	RSHIFTEQ:  ASSIGN_PREC,
 	OR:        LOGOR,
 	AND:       LOGAND,
 	BITOR:     BITOR_PREC,
@@ -538,6 +1488,8 @@ var precedences = map[TokenType]int{
 	DEC:       POSTFIX,
 	LPAREN:    CALL,
 	LBRACKET:  INDEX,
+	DOT:       INDEX,
+	ARROW:     INDEX,
 	QUESTION:  CONDITIONAL,
 }
 
@@ -572,24 +1524,40 @@ func (p *Parser) parseExpression(precedence int) Expression {
 
 	switch p.curToken.Type {
 	case IDENT:
-		leftExp = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if sym, ok := p.lookup(p.curToken.Literal); ok && sym.kind == symEnumConst {
+			// Enumerators are integer constants
+			leftExp = &IntegerLiteral{Token: p.curToken, Value: sym.value, Type: "int"}
+		} else {
+			leftExp = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
 	case INT:
-		val, _ := strconv.ParseInt(p.curToken.Literal, 10, 64)
-		leftExp = &IntegerLiteral{Token: p.curToken, Value: val}
+		leftExp = p.parseIntegerLiteral()
 	case FLOAT:
-		val, _ := strconv.ParseFloat(p.curToken.Literal, 64)
-		leftExp = &FloatLiteral{Token: p.curToken, Value: val}
+		leftExp = p.parseFloatLiteral()
 	case STRING:
-		leftExp = &StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+		lit := &StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+		// Adjacent string literals are concatenated
+		for p.peekTokenIs(STRING) {
+			p.nextToken()
+			lit.Value += p.curToken.Literal
+		}
+		leftExp = lit
 	case CHAR:
-		var val byte
-		if len(p.curToken.Literal) > 0 {
-			val = p.curToken.Literal[0]
+		if len(p.curToken.Literal) != 1 {
+			p.errors = append(p.errors, fmt.Sprintf("character constant must hold exactly one character at %s", p.curToken.Pos()))
+			return nil
 		}
-		leftExp = &CharLiteral{Token: p.curToken, Value: val}
+		leftExp = &CharLiteral{Token: p.curToken, Value: p.curToken.Literal[0]}
 	case MINUS, NOT, BITNOT, INC, DEC, STAR, BITAND:
 		leftExp = p.parsePrefixExpression()
+	case SIZEOF:
+		leftExp = p.parseSizeofExpression()
 	case LPAREN:
+		// A type name in parentheses is a cast rather than a grouping
+		if p.isTypeName(p.peekToken) {
+			leftExp = p.parseCastExpression()
+			break
+		}
 		p.nextToken()
 		leftExp = p.parseExpression(LOWEST)
 		if !p.expectPeek(RPAREN) {
@@ -605,10 +1573,11 @@ func (p *Parser) parseExpression(precedence int) Expression {
 		case PLUS, MINUS, STAR, SLASH, PERCENT,
 			EQ, NEQ, LT, GT, LTE, GTE,
 			AND, OR, BITAND, BITOR, BITXOR,
-			LSHIFT, RSHIFT:
+			LSHIFT, RSHIFT, COMMA:
 			p.nextToken()
 			leftExp = p.parseInfixExpression(leftExp)
-		case ASSIGN, PLUSEQ, MINUSEQ, STAREQ, SLASHEQ, PERCENTEQ:
+		case ASSIGN, PLUSEQ, MINUSEQ, STAREQ, SLASHEQ, PERCENTEQ,
+			ANDEQ, OREQ, XOREQ, LSHIFTEQ, RSHIFTEQ:
 			p.nextToken()
 			leftExp = p.parseAssignmentExpression(leftExp)
 		case INC, DEC:
@@ -624,6 +1593,9 @@ func (p *Parser) parseExpression(precedence int) Expression {
 		case LBRACKET:
 			p.nextToken()
 			leftExp = p.parseArrayExpression(leftExp)
+		case DOT, ARROW:
+			p.nextToken()
+			leftExp = p.parseMemberExpression(leftExp)
 		case QUESTION:
 			p.nextToken()
 			leftExp = p.parseConditionalExpression(leftExp)
@@ -635,6 +1607,131 @@ func (p *Parser) parseExpression(precedence int) Expression {
 	return leftExp
 }
 
+// parseIntegerLiteral parses the integer constant at the current token, which may be
+// decimal, octal with a leading 0, or hexadecimal with a leading 0x, and may carry
+// U and L suffixes in either case. Its type is the first of the following in which
+// its value fits, as in C: int, unsigned int (unless decimal without U), long and
+// unsigned long, skipping int and unsigned int after an L suffix and the signed
+// types after a U. Returns nil after recording an error for a malformed constant.
+func (p *Parser) parseIntegerLiteral() Expression {
+	lit := p.curToken.Literal
+	digits := strings.TrimRight(lit, "uUlL")
+	suffix := strings.ToLower(lit[len(digits):])
+
+	base := 10
+	switch {
+	case strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X"):
+		base, digits = 16, digits[2:]
+	case len(digits) > 1 && digits[0] == '0':
+		base, digits = 8, digits[1:]
+	}
+
+	val, err := strconv.ParseUint(digits, base, 64)
+	unsigned := strings.Count(suffix, "u")
+	long := strings.Count(suffix, "l")
+	if err != nil || unsigned > 1 || long > 2 || (long == 2 && !strings.Contains(suffix, "ll")) {
+		p.errors = append(p.errors, fmt.Sprintf("invalid integer constant %s at %s", lit, p.curToken.Pos()))
+		return nil
+	}
+
+	candidates := []string{"int", "unsigned int", "long", "unsigned long"}
+	if long > 0 {
+		candidates = candidates[2:]
+	}
+	typ := "unsigned long"
+	for _, candidate := range candidates {
+		if unsigned > 0 && !isUnsignedType(candidate) || base == 10 && unsigned == 0 && isUnsignedType(candidate) {
+			continue
+		}
+		if fitsType(val, candidate) {
+			typ = candidate
+			break
+		}
+	}
+
+	return &IntegerLiteral{Token: p.curToken, Value: int64(val), Type: typ}
+}
+
+// parseFloatLiteral parses the floating constant at the current token. A constant with
+// an F suffix is a float, and one with an L suffix, which would be a long double in C,
+// is a double like an unsuffixed constant. Returns nil after recording an error for a
+// malformed constant.
+func (p *Parser) parseFloatLiteral() Expression {
+	lit := p.curToken.Literal
+	digits, typ := lit, "double"
+	switch lit[len(lit)-1] {
+	case 'f', 'F':
+		digits, typ = lit[:len(lit)-1], "float"
+	case 'l', 'L':
+		digits = lit[:len(lit)-1]
+	}
+
+	val, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		p.errors = append(p.errors, fmt.Sprintf("invalid floating constant %s at %s", lit, p.curToken.Pos()))
+		return nil
+	}
+	return &FloatLiteral{Token: p.curToken, Value: roundFloat(val, typ), Type: typ}
+}
+
+// parseCastExpression parses a cast such as (double)sum or (char *)p, starting at the
+// opening parenthesis. The operand binds as tightly as a prefix operator's, so
+// (double)sum / n converts sum before dividing. Returns nil if the type name is not
+// followed by a closing parenthesis or an operand.
+func (p *Parser) parseCastExpression() Expression {
+	cast := &CastExpression{Token: p.curToken}
+
+	p.nextToken()
+	typ, ok := p.parseTypeName()
+	if !ok {
+		return nil
+	}
+	cast.Type = typ
+	if !p.curTokenIs(RPAREN) {
+		p.errors = append(p.errors, fmt.Sprintf("expected ) after type name in cast, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+		return nil
+	}
+
+	p.nextToken()
+	cast.Right = p.parseExpression(PREFIX)
+	if cast.Right == nil {
+		return nil
+	}
+	return cast
+}
+
+// parseSizeofExpression parses either sizeof(type), where the type may include array
+// dimensions as in sizeof(int[10]), or sizeof applied to a unary expression, as in
+// sizeof a[0] or sizeof(a). Returns nil if the operand is missing or malformed.
+func (p *Parser) parseSizeofExpression() Expression {
+	expr := &SizeofExpression{Token: p.curToken}
+
+	if p.peekTokenIs(LPAREN) {
+		p.nextToken()
+		if p.isTypeName(p.peekToken) {
+			p.nextToken()
+			typ, ok := p.parseTypeName()
+			if !ok {
+				return nil
+			}
+			if !p.curTokenIs(RPAREN) {
+				p.errors = append(p.errors, fmt.Sprintf("expected ) after type name in sizeof, got '%s' at %s", p.curToken.Literal, p.curToken.Pos()))
+				return nil
+			}
+			expr.Type = typ
+			return expr
+		}
+	} else {
+		p.nextToken()
+	}
+
+	expr.Operand = p.parseExpression(PREFIX)
+	if expr.Operand == nil {
+		return nil
+	}
+	return expr
+}
+
 // parsePrefixExpression parses a prefix expression from the current token stream.
 // It constructs a PrefixExpression node using the current token as the operator,
 // advances to the next token, and recursively parses the right-hand side expression
@@ -671,7 +1768,8 @@ func (p *Parser) parseInfixExpression(left Expression) Expression {
 
 // parseAssignmentExpression parses an assignment expression starting from the given left-hand side expression.
 // It constructs an AssignmentExpression node with the current token as the assignment operator,
-// advances the parser to the next token, and parses the right-hand side expression with assignment precedence.
+// advances the parser to the next token, and parses the right-hand side expression. Assignment is
+// right-associative, so the right-hand side may itself be an assignment, as in a = b = 0.
 // Returns the constructed AssignmentExpression as an Expression.
 func (p *Parser) parseAssignmentExpression(left Expression) Expression {
 	expression := &AssignmentExpression{
@@ -681,7 +1779,7 @@ func (p *Parser) parseAssignmentExpression(left Expression) Expression {
 	}
 
 	p.nextToken()
-	expression.Right = p.parseExpression(ASSIGN_PREC)
+	expression.Right = p.parseExpression(COMMA_PREC)
 
 	return expression
 }
@@ -713,6 +1811,20 @@ func (p *Parser) parseArrayExpression(left Expression) Expression {
 	return exp
 }
 
+// parseMemberExpression parses a member access such as s.x or p->x, given the
+// already-parsed expression to the left of the '.' or '->'. Returns nil if the
+// operator is not followed by a member name.
+func (p *Parser) parseMemberExpression(left Expression) Expression {
+	exp := &MemberExpression{Token: p.curToken, Left: left, Operator: p.curToken.Literal}
+
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+	exp.Member = p.curToken.Literal
+
+	return exp
+}
+
 // parseConditionalExpression parses a conditional (ternary) expression of the form
 // "condition ? consequence : alternative". It takes the already-parsed condition
 // expression as input, then parses the consequence and alternative expressions,
@@ -749,12 +1861,12 @@ func (p *Parser) parseExpressionList(end TokenType) []Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(COMMA_PREC))
 
 	for p.peekTokenIs(COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(COMMA_PREC))
 	}
 
 	if !p.expectPeek(end) {
@@ -763,3 +1875,136 @@ func (p *Parser) parseExpressionList(end TokenType) []Expression {
 
 	return list
 }
+
+// typeContext returns an interpreter and environment that know only the types
+// declared so far: the variables in scope, and the struct, union and function
+// declarations seen. They let the parser work out types and sizes exactly as the
+// interpreter will, without running anything.
+func (p *Parser) typeContext() (*Interpreter, *Environment) {
+	i := &Interpreter{functions: p.functions, structs: p.structs, layouts: make(map[string]*layout)}
+	var env *Environment
+	for _, scope := range p.scopes {
+		env = NewEnclosedEnvironment(env)
+		for name, sym := range scope {
+			if sym.kind == symVariable && sym.typ != "" {
+				env.Set(name, &Variable{Type: sym.typ})
+			}
+		}
+	}
+	return i, env
+}
+
+// constantValue evaluates an integer constant expression at parse time, as needed
+// for array dimensions. It understands integer and character literals combined with
+// the arithmetic, bitwise and comparison operators, casts to integer types, and
+// sizeof applied to any type or expression whose size is known from the declarations
+// so far. The second result is false if the expression is not a constant the parser
+// can evaluate.
+func (p *Parser) constantValue(expr Expression) (int64, bool) {
+	switch node := expr.(type) {
+	case *IntegerLiteral:
+		return node.Value, true
+	case *CharLiteral:
+		return int64(int8(node.Value)), true
+	case *PrefixExpression:
+		right, ok := p.constantValue(node.Right)
+		if !ok {
+			return 0, false
+		}
+		switch node.Operator {
+		case "-":
+			return -right, true
+		case "+":
+			return right, true
+		case "~":
+			return ^right, true
+		case "!":
+			return boolToInt(right == 0), true
+		}
+	case *InfixExpression:
+		left, ok := p.constantValue(node.Left)
+		if !ok {
+			return 0, false
+		}
+		// As at run time, the right operand of && and || is only evaluated if needed
+		if node.Operator == "&&" && left == 0 {
+			return 0, true
+		}
+		if node.Operator == "||" && left != 0 {
+			return 1, true
+		}
+		right, ok := p.constantValue(node.Right)
+		if !ok {
+			return 0, false
+		}
+		switch node.Operator {
+		case "+":
+			return left + right, true
+		case "-":
+			return left - right, true
+		case "*":
+			return left * right, true
+		case "/":
+			if right == 0 {
+				return 0, false
+			}
+			return left / right, true
+		case "%":
+			if right == 0 {
+				return 0, false
+			}
+			return left % right, true
+		case "<<":
+			return left << uint(right), true
+		case ">>":
+			return left >> uint(right), true
+		case "&":
+			return left & right, true
+		case "|":
+			return left | right, true
+		case "^":
+			return left ^ right, true
+		case "<":
+			return boolToInt(left < right), true
+		case ">":
+			return boolToInt(left > right), true
+		case "<=":
+			return boolToInt(left <= right), true
+		case ">=":
+			return boolToInt(left >= right), true
+		case "==":
+			return boolToInt(left == right), true
+		case "!=":
+			return boolToInt(left != right), true
+		case "&&":
+			return boolToInt(left != 0 && right != 0), true
+		case "||":
+			return boolToInt(left != 0 || right != 0), true
+		}
+	case *SizeofExpression:
+		i, env := p.typeContext()
+		if val, err := i.evalSizeofExpression(node, env); err == nil {
+			return val.Int, true
+		}
+	case *CastExpression:
+		// Only a cast to an integer type keeps the expression an integer constant
+		if !isIntegerType(node.Type) {
+			return 0, false
+		}
+		val, ok := p.constantValue(node.Right)
+		if !ok {
+			return 0, false
+		}
+		return convertInt(val, node.Type), true
+	case *ConditionalExpression:
+		cond, ok := p.constantValue(node.Condition)
+		if !ok {
+			return 0, false
+		}
+		if cond != 0 {
+			return p.constantValue(node.Consequence)
+		}
+		return p.constantValue(node.Alternative)
+	}
+	return 0, false
+}
@@ -0,0 +1,1009 @@
+package cint
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// headers holds the standard headers that declare the built-in functions. They are
+// found by #include after the include directories have been searched.
+//
+//go:embed include/*.h
+var headers embed.FS
+
+// maxIncludeDepth bounds the nesting of #include, so that a file that includes
+// itself is reported rather than read forever.
+const maxIncludeDepth = 200
+
+// placemarker is the type of the empty token that stands in for an empty macro
+// argument next to a ## operator. Placemarkers are removed once pasting is done.
+const placemarker TokenType = -1
+
+// macro is a macro created by #define. A function-like macro has a list of
+// parameter names, the last of which is __VA_ARGS__ if the macro is variadic.
+type macro struct {
+	name     string
+	funcLike bool
+	params   []string
+	variadic bool
+	body     []Token
+}
+
+// param returns the index of the parameter that tok names, or -1 if it names none.
+func (m *macro) param(tok Token) int {
+	if !m.funcLike || !isName(tok) {
+		return -1
+	}
+	return slices.Index(m.params, tok.Literal)
+}
+
+// Preprocessor carries out the preprocessing directives in the tokens read from a
+// Lexer and expands the macros they define, handing the resulting tokens to the
+// parser one at a time. Expansion follows the C standard: the result of expanding a
+// macro is rescanned for further macros, and each token records the macros it came
+// from so that a macro is never expanded again inside its own expansion. Tokens
+// produced by an expansion carry the file, line and column of the macro invocation.
+type Preprocessor struct {
+	l      *Lexer
+	macros map[string]*macro
+	errors []string
+
+	// pending holds tokens that have been read or produced by an expansion but not
+	// yet scanned, in reverse order so that the next token is last.
+	pending []Token
+
+	// file is the path of the file being read, or "" for the main source, and
+	// includes holds the files that included it, innermost last.
+	file     string
+	includes []includeFile
+
+	// fsys and includeDirs are where #include looks for files, and once records
+	// the files that have used #pragma once.
+	fsys        fs.FS
+	includeDirs []string
+	once        map[string]bool
+
+	// conds holds the conditional directives of the current file whose #endif has
+	// not yet been reached, innermost last.
+	conds []conditional
+
+	// inIf is set while the condition of an #if or #elif is expanded. The operand
+	// of defined is then left unexpanded, even when the defined operator itself
+	// comes from the expansion of a macro.
+	inIf bool
+}
+
+// includeFile records where reading of a file stopped to read a file it included.
+type includeFile struct {
+	l       *Lexer
+	file    string
+	pending []Token
+	conds   []conditional
+}
+
+// conditional is an #if, #ifdef or #ifndef directive, named by name and begun by
+// the # token hash, whose #endif has not been reached. Once one of its groups has
+// been included, taken is set and the groups after it are skipped.
+type conditional struct {
+	name    string
+	hash    Token
+	taken   bool
+	sawElse bool
+}
+
+// NewPreprocessor creates and returns a Preprocessor that reads its input from l.
+func NewPreprocessor(l *Lexer) *Preprocessor {
+	return &Preprocessor{l: l, macros: make(map[string]*macro), errors: []string{}, once: make(map[string]bool)}
+}
+
+// Define defines a macro as if by "#define name value" before the first line of
+// input, like the -D option of a C compiler. The name may include a parameter list
+// to define a function-like macro, as in Define("SQUARE(x)", "((x) * (x))").
+func (pp *Preprocessor) Define(name, value string) error {
+	l := NewLexer(name + " " + value)
+	var line []Token
+	for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+		line = append(line, tok)
+	}
+	if err := pp.define(line); err != nil {
+		return fmt.Errorf("cannot define %s: %v", name, err)
+	}
+	return nil
+}
+
+// SetFS sets the file system that #include reads files from. A file named in
+// quotes is looked for first in the directory of the file that includes it and
+// then, like a file named in angle brackets, in each of includeDirs in turn.
+// Paths are interpreted as for fs.FS, with the main source in the root directory.
+// The standard headers built into cint are found last.
+func (pp *Preprocessor) SetFS(fsys fs.FS, includeDirs ...string) {
+	pp.fsys = fsys
+	pp.includeDirs = includeDirs
+}
+
+// Errors returns the errors found while preprocessing.
+func (pp *Preprocessor) Errors() []string {
+	return pp.errors
+}
+
+// NextToken returns the next token of the program after preprocessing. Directives
+// met on the way are carried out, and macro invocations are replaced by their
+// expansions.
+func (pp *Preprocessor) NextToken() Token {
+	for {
+		tok := pp.next()
+		if tok.Type == EOF {
+			for _, c := range pp.conds {
+				pp.errors = append(pp.errors, fmt.Sprintf("unterminated #%s at %s", c.name, c.hash.Pos()))
+			}
+			pp.conds = nil
+
+			if len(pp.includes) > 0 {
+				// Continue in the file that included this one
+				outer := pp.includes[len(pp.includes)-1]
+				pp.includes = pp.includes[:len(pp.includes)-1]
+				pp.l, pp.file, pp.pending, pp.conds = outer.l, outer.file, outer.pending, outer.conds
+				continue
+			}
+		}
+		if tok.Type == HASH && tok.LineStart && pp.l != nil {
+			pp.directive(tok)
+			continue
+		}
+
+		if !isName(tok) || slices.Contains(tok.hide, tok.Literal) {
+			return tok
+		}
+		if pp.inIf && tok.Literal == "defined" {
+			pp.hideOperand()
+			return tok
+		}
+		m, ok := pp.macros[tok.Literal]
+		if !ok || !pp.expand(tok, m) {
+			return tok
+		}
+	}
+}
+
+// next returns the next token without expanding it, taking it from pending if any
+// are left and otherwise from the lexer.
+func (pp *Preprocessor) next() Token {
+	if n := len(pp.pending); n > 0 {
+		tok := pp.pending[n-1]
+		pp.pending = pp.pending[:n-1]
+		return tok
+	}
+	if pp.l == nil {
+		return Token{Type: EOF}
+	}
+	tok := pp.l.NextToken()
+	tok.File = pp.file
+	return tok
+}
+
+// hideOperand stops the name that follows the defined operator, with or without
+// parentheses, from being expanded, since it names a macro rather than using it.
+func (pp *Preprocessor) hideOperand() {
+	next := pp.next()
+	if next.Type == LPAREN {
+		name := pp.next()
+		name.hide = addHide(name.hide, name.Literal)
+		pp.unread(next, name)
+		return
+	}
+	next.hide = addHide(next.hide, next.Literal)
+	pp.unread(next)
+}
+
+// unread pushes toks back so that they are returned next, in order.
+func (pp *Preprocessor) unread(toks ...Token) {
+	for i := len(toks) - 1; i >= 0; i-- {
+		pp.pending = append(pp.pending, toks[i])
+	}
+}
+
+// expand replaces the invocation of m that begins with tok by its expansion, which is
+// pushed back to be rescanned. It returns false if tok is the name of a function-like
+// macro that is not followed by an argument list, and so is not an invocation.
+func (pp *Preprocessor) expand(tok Token, m *macro) bool {
+	var args [][]Token
+	hide := tok.hide
+	if m.funcLike {
+		next := pp.next()
+		if next.Type != LPAREN {
+			pp.unread(next)
+			return false
+		}
+		var rparen Token
+		var ok bool
+		args, rparen, ok = pp.readArgs(tok, m)
+		if !ok {
+			return true
+		}
+		// Only macros whose expansion covers the whole invocation stay hidden
+		hide = nil
+		for _, name := range tok.hide {
+			if slices.Contains(rparen.hide, name) {
+				hide = append(hide, name)
+			}
+		}
+	}
+	hide = addHide(hide, m.name)
+
+	out := pp.subst(tok, m, args)
+	for i := range out {
+		out[i].Line = tok.Line
+		out[i].File = tok.File
+		out[i].Column = tok.Column
+		out[i].LineStart = false
+		out[i].hide = addHide(out[i].hide, hide...)
+	}
+	if len(out) > 0 {
+		out[0].Space = tok.Space
+	}
+	pp.unread(out...)
+	return true
+}
+
+// readArgs reads the arguments of an invocation of the function-like macro m, whose
+// opening parenthesis has been read, and returns them along with the closing
+// parenthesis. Arguments are separated by commas that are not nested inside
+// parentheses. Returns false if the argument list is unterminated or the number of
+// arguments does not match the macro.
+func (pp *Preprocessor) readArgs(tok Token, m *macro) ([][]Token, Token, bool) {
+	var args [][]Token
+	arg := []Token{}
+	depth := 0
+	for {
+		t := pp.next()
+		switch {
+		case t.Type == EOF:
+			pp.unread(t)
+			pp.errors = append(pp.errors, fmt.Sprintf("unterminated argument list invoking macro %s at %s", m.name, tok.Pos()))
+			return nil, t, false
+		case t.Type == LPAREN:
+			depth++
+		case t.Type == RPAREN && depth > 0:
+			depth--
+		case t.Type == RPAREN:
+			args, ok := pp.checkArgs(tok, m, append(args, arg))
+			return args, t, ok
+		case t.Type == COMMA && depth == 0 && !(m.variadic && len(args) == len(m.params)-1):
+			// The variable arguments keep their commas
+			args = append(args, arg)
+			arg = []Token{}
+			continue
+		}
+		arg = append(arg, t)
+	}
+}
+
+// checkArgs checks that args, the arguments of an invocation of m, match its
+// parameters. The empty argument list of a macro without parameters is dropped,
+// and a variadic macro given no variable arguments gets an empty one.
+func (pp *Preprocessor) checkArgs(tok Token, m *macro, args [][]Token) ([][]Token, bool) {
+	if len(m.params) == 0 && len(args) == 1 && len(args[0]) == 0 {
+		return nil, true
+	}
+	if m.variadic && len(args) == len(m.params)-1 {
+		args = append(args, []Token{})
+	}
+
+	switch {
+	case len(args) < len(m.params):
+		pp.errors = append(pp.errors, fmt.Sprintf("macro %s requires %d arguments, but only %d given at %s", m.name, len(m.params), len(args), tok.Pos()))
+		return nil, false
+	case len(args) > len(m.params):
+		pp.errors = append(pp.errors, fmt.Sprintf("macro %s passed %d arguments, but takes just %d at %s", m.name, len(args), len(m.params), tok.Pos()))
+		return nil, false
+	}
+	return args, true
+}
+
+// subst returns the replacement list of m, invoked by tok, with its parameters
+// replaced by args. A parameter preceded by # is replaced by its argument as a
+// string literal, and one next to ## by the argument as written; any other parameter
+// is replaced by its argument after the argument's own macros have been expanded.
+// The tokens on either side of each ## are pasted together into one.
+func (pp *Preprocessor) subst(tok Token, m *macro, args [][]Token) []Token {
+	var out []Token
+	body := m.body
+	for i := 0; i < len(body); i++ {
+		t := body[i]
+		switch {
+		case t.Type == HASH && m.funcLike:
+			i++
+			out = append(out, stringize(t, args[m.param(body[i])]))
+
+		case t.Type == HASHHASH:
+			i++
+			rhs := []Token{body[i]}
+			if idx := m.param(body[i]); idx >= 0 {
+				rhs = args[idx]
+			}
+			if len(rhs) == 0 {
+				rhs = []Token{{Type: placemarker}}
+			}
+			lhs := out[len(out)-1]
+			out = append(out[:len(out)-1], pp.paste(tok, lhs, rhs[0])...)
+			out = append(out, rhs[1:]...)
+
+		case m.param(t) >= 0:
+			arg := args[m.param(t)]
+			if i+1 < len(body) && body[i+1].Type == HASHHASH {
+				if len(arg) == 0 {
+					arg = []Token{{Type: placemarker}}
+				}
+			} else {
+				arg = pp.expandArg(arg)
+			}
+			if len(arg) > 0 {
+				arg = slices.Clone(arg)
+				arg[0].Space = t.Space
+			}
+			out = append(out, arg...)
+
+		default:
+			out = append(out, t)
+		}
+	}
+
+	return slices.DeleteFunc(out, func(t Token) bool { return t.Type == placemarker })
+}
+
+// expandArg returns the tokens of a macro argument with every macro in them
+// expanded. The argument is expanded on its own, as if it were the whole input.
+func (pp *Preprocessor) expandArg(arg []Token) []Token {
+	return pp.expandTokens(arg, false)
+}
+
+// expandTokens returns toks with every macro in them expanded, as if they were the
+// whole input. If inIf is set they are the condition of an #if or #elif, and the
+// operands of defined are left alone.
+func (pp *Preprocessor) expandTokens(toks []Token, inIf bool) []Token {
+	sub := &Preprocessor{macros: pp.macros, inIf: inIf}
+	sub.unread(toks...)
+
+	var out []Token
+	for {
+		tok := sub.NextToken()
+		if tok.Type == EOF {
+			break
+		}
+		out = append(out, tok)
+	}
+	pp.errors = append(pp.errors, sub.errors...)
+	return out
+}
+
+// paste joins lhs and rhs, the operands of a ## operator in the expansion of the
+// macro invoked by inv, into a single token. If their spellings together do not
+// form one valid token, an error is recorded and both are returned unchanged.
+func (pp *Preprocessor) paste(inv, lhs, rhs Token) []Token {
+	if lhs.Type == placemarker {
+		return []Token{rhs}
+	}
+	if rhs.Type == placemarker {
+		return []Token{lhs}
+	}
+
+	l := NewLexer(spell(lhs) + spell(rhs))
+	tok := l.NextToken()
+	if tok.Type == ILLEGAL || l.NextToken().Type != EOF {
+		pp.errors = append(pp.errors, fmt.Sprintf("pasting %s and %s does not give a valid preprocessing token at %s", spell(lhs), spell(rhs), inv.Pos()))
+		return []Token{lhs, rhs}
+	}
+	tok.Space = lhs.Space
+	tok.LineStart = false
+	tok.hide = lhs.hide
+	return []Token{tok}
+}
+
+// stringize returns the string literal that the # operator op makes of arg, whose
+// text is the spelling of the argument's tokens with a single space wherever the
+// tokens were separated by white space.
+func stringize(op Token, arg []Token) Token {
+	return Token{Type: STRING, Literal: spellLine(arg), Line: op.Line, Column: op.Column, Space: op.Space}
+}
+
+// spell returns the source text of tok. String and character literals are spelled
+// as they were written; the contents of one made by the # operator, which has no
+// source text, are escaped again so that the text reads back as the same literal.
+func spell(tok Token) string {
+	switch {
+	case tok.Raw != "":
+		return tok.Raw
+	case tok.Type == STRING:
+		return `"` + escapeQuoted(tok.Literal, '"') + `"`
+	case tok.Type == CHAR:
+		return "'" + escapeQuoted(tok.Literal, '\'') + "'"
+	case tok.Type == placemarker:
+		return ""
+	}
+	return tok.Literal
+}
+
+// escapeQuoted returns s written as the contents of a literal delimited by quote,
+// with escape sequences for the quote, backslashes and unprintable characters.
+func escapeQuoted(s string, quote byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == quote || ch == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(ch)
+		case ch == '\n':
+			sb.WriteString(`\n`)
+		case ch == '\t':
+			sb.WriteString(`\t`)
+		case ch < ' ' || ch > '~':
+			fmt.Fprintf(&sb, "\\%03o", ch)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+// isName reports whether tok is an identifier. Keywords count, since a macro may
+// have the same name as a keyword.
+func isName(tok Token) bool {
+	if tok.Type == IDENT {
+		return true
+	}
+	kw, ok := keywords[tok.Literal]
+	return ok && kw == tok.Type
+}
+
+// addHide returns hide with names added to it. The slice hide is not modified.
+func addHide(hide []string, names ...string) []string {
+	out := slices.Clip(hide)
+	for _, name := range names {
+		if !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// directive carries out the preprocessing directive introduced by hash, which
+// extends to the end of its line.
+func (pp *Preprocessor) directive(hash Token) {
+	line := pp.readLine()
+	if len(line) == 0 {
+		// The null directive
+		return
+	}
+
+	name, args := line[0].Literal, line[1:]
+	var err error
+	switch name {
+	case "define":
+		err = pp.define(args)
+	case "undef":
+		err = pp.undef(args)
+	case "include":
+		err = pp.include(args)
+	case "if", "ifdef", "ifndef":
+		var taken bool
+		taken, err = pp.condition(name, args)
+		pp.conds = append(pp.conds, conditional{name: name, hash: hash, taken: taken})
+		if !taken {
+			pp.skip()
+		}
+	case "elif":
+		if len(pp.conds) == 0 {
+			err = errors.New("#elif without #if")
+			break
+		}
+		c := &pp.conds[len(pp.conds)-1]
+		if c.sawElse {
+			err = errors.New("#elif after #else")
+		}
+		if c.taken || c.sawElse {
+			pp.skip()
+			break
+		}
+		c.taken, err = pp.condition(name, args)
+		if !c.taken {
+			pp.skip()
+		}
+	case "else":
+		if len(pp.conds) == 0 {
+			err = errors.New("#else without #if")
+			break
+		}
+		c := &pp.conds[len(pp.conds)-1]
+		if c.sawElse {
+			err = errors.New("#else after #else")
+		} else if len(args) > 0 {
+			err = errors.New("extra tokens at end of #else directive")
+		}
+		c.sawElse = true
+		if c.taken {
+			pp.skip()
+		}
+		c.taken = true
+	case "endif":
+		if len(pp.conds) == 0 {
+			err = errors.New("#endif without #if")
+			break
+		}
+		if len(args) > 0 {
+			err = errors.New("extra tokens at end of #endif directive")
+		}
+		pp.conds = pp.conds[:len(pp.conds)-1]
+	case "error":
+		err = fmt.Errorf("#error %s", spellLine(args))
+	case "pragma":
+		// Pragmas other than once are ignored, as C allows
+		if len(args) == 1 && args[0].Literal == "once" {
+			pp.once[pp.file] = true
+		}
+	default:
+		err = fmt.Errorf("invalid preprocessing directive #%s", name)
+	}
+
+	if err != nil {
+		pp.errors = append(pp.errors, fmt.Sprintf("%v at %s", err, hash.Pos()))
+	}
+}
+
+// readLine returns the tokens up to the end of the current line. Nothing on the
+// next line is read, since it may be text that the directive leaves out.
+func (pp *Preprocessor) readLine() []Token {
+	var line []Token
+	for {
+		if len(pp.pending) == 0 && pp.l != nil && pp.l.atLineEnd() {
+			return line
+		}
+		tok := pp.next()
+		if tok.Type == EOF || tok.LineStart {
+			pp.unread(tok)
+			return line
+		}
+		line = append(line, tok)
+	}
+}
+
+// spellLine returns the text of the tokens in line, separated by single spaces
+// where there was white space between them.
+func spellLine(line []Token) string {
+	var sb strings.Builder
+	for i, tok := range line {
+		if i > 0 && tok.Space {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(spell(tok))
+	}
+	return sb.String()
+}
+
+// define handles a #define directive whose tokens after the directive name are
+// line. The macro is function-like if its name is followed immediately by a
+// parenthesis, with no white space between. Redefining a macro is an error unless
+// the new definition is identical to the old one.
+func (pp *Preprocessor) define(line []Token) error {
+	if len(line) == 0 || !isName(line[0]) {
+		return errors.New("macro names must be identifiers")
+	}
+
+	m := &macro{name: line[0].Literal}
+	body := line[1:]
+	if len(body) > 0 && body[0].Type == LPAREN && !body[0].Space {
+		m.funcLike = true
+		var err error
+		if body, err = defineParams(m, body[1:]); err != nil {
+			return err
+		}
+	}
+	m.body = body
+
+	if len(body) > 0 && (body[0].Type == HASHHASH || body[len(body)-1].Type == HASHHASH) {
+		return errors.New("'##' cannot appear at either end of a macro expansion")
+	}
+	for i, tok := range body {
+		if m.funcLike && tok.Type == HASH && (i+1 == len(body) || m.param(body[i+1]) < 0) {
+			return errors.New("'#' is not followed by a macro parameter")
+		}
+	}
+
+	if prev, ok := pp.macros[m.name]; ok && !sameMacro(prev, m) {
+		return fmt.Errorf("redefinition of macro %s", m.name)
+	}
+	pp.macros[m.name] = m
+	return nil
+}
+
+// defineParams reads the parameter list of the function-like macro m from toks, which
+// follow the opening parenthesis, and returns the tokens after the closing one. A
+// final ... makes the macro variadic, with __VA_ARGS__ naming the variable arguments.
+func defineParams(m *macro, toks []Token) ([]Token, error) {
+	if len(toks) > 0 && toks[0].Type == RPAREN {
+		return toks[1:], nil
+	}
+
+	for i := 0; i < len(toks); i += 2 {
+		tok := toks[i]
+		switch {
+		case tok.Type == ELLIPSIS:
+			m.variadic = true
+			m.params = append(m.params, "__VA_ARGS__")
+		case isName(tok) && tok.Literal != "__VA_ARGS__":
+			if slices.Contains(m.params, tok.Literal) {
+				return nil, fmt.Errorf("duplicate macro parameter %s", tok.Literal)
+			}
+			m.params = append(m.params, tok.Literal)
+		default:
+			return nil, fmt.Errorf("expected parameter name in macro parameter list, got '%s'", tok.Literal)
+		}
+
+		if i+1 < len(toks) && toks[i+1].Type == RPAREN {
+			return toks[i+2:], nil
+		}
+		if m.variadic || i+1 == len(toks) || toks[i+1].Type != COMMA {
+			break
+		}
+	}
+	return nil, errors.New("missing ')' in macro parameter list")
+}
+
+// undef handles an #undef directive whose tokens after the directive name are line.
+// Removing a macro that is not defined does nothing.
+func (pp *Preprocessor) undef(line []Token) error {
+	if len(line) == 0 || !isName(line[0]) {
+		return errors.New("macro names must be identifiers")
+	}
+	delete(pp.macros, line[0].Literal)
+	if len(line) > 1 {
+		return errors.New("extra tokens at end of #undef directive")
+	}
+	return nil
+}
+
+// condition evaluates the condition of the #if, #ifdef, #ifndef or #elif directive
+// name, whose tokens after the directive name are line. A condition that cannot be
+// evaluated is false.
+func (pp *Preprocessor) condition(name string, line []Token) (bool, error) {
+	if name == "if" || name == "elif" {
+		val, err := pp.evaluate(line)
+		if err != nil {
+			return false, fmt.Errorf("#%s: %v", name, err)
+		}
+		return val != 0, nil
+	}
+
+	if len(line) == 0 || !isName(line[0]) {
+		return false, fmt.Errorf("no macro name given in #%s directive", name)
+	}
+	_, defined := pp.macros[line[0].Literal]
+	if len(line) > 1 {
+		return defined == (name == "ifdef"), fmt.Errorf("extra tokens at end of #%s directive", name)
+	}
+	return defined == (name == "ifdef"), nil
+}
+
+// evaluate returns the value of the integer constant expression in line, the
+// condition of an #if or #elif directive. Macros are expanded first, apart from the
+// operands of the defined operator. Then each use of defined, written "defined X"
+// or "defined(X)", is replaced by 1 if X is a macro and 0 otherwise, even one that
+// came from a macro, as in "#define HAVE_X defined(X)". Any identifiers that remain,
+// keywords included, are replaced by 0. The expression is parsed by the parser and
+// evaluated by evalCondition.
+func (pp *Preprocessor) evaluate(line []Token) (int64, error) {
+	line = pp.expandTokens(line, true)
+	var toks []Token
+	for i := 0; i < len(line); i++ {
+		tok := line[i]
+		if tok.Type != IDENT || tok.Literal != "defined" {
+			toks = append(toks, tok)
+			continue
+		}
+
+		paren := i+1 < len(line) && line[i+1].Type == LPAREN
+		if paren {
+			i++
+		}
+		if i+1 >= len(line) || !isName(line[i+1]) {
+			return 0, errors.New("operator \"defined\" requires an identifier")
+		}
+		i++
+		_, defined := pp.macros[line[i].Literal]
+		if paren {
+			if i+1 >= len(line) || line[i+1].Type != RPAREN {
+				return 0, errors.New("missing ')' after \"defined\"")
+			}
+			i++
+		}
+		toks = append(toks, Token{Type: INT, Literal: fmt.Sprint(boolToInt(defined)), Line: tok.Line, Column: tok.Column})
+	}
+
+	if len(toks) == 0 {
+		return 0, errors.New("missing expression")
+	}
+	for i, tok := range toks {
+		if isName(tok) {
+			toks[i] = Token{Type: INT, Literal: "0", Line: tok.Line, Column: tok.Column}
+		}
+	}
+
+	src := tokenList(toks)
+	p := NewParser(&src)
+	expr := p.parseExpression(LOWEST)
+	if len(p.Errors()) > 0 || !p.peekTokenIs(EOF) {
+		return 0, fmt.Errorf("invalid expression %s", spellLine(toks))
+	}
+	val, err := evalCondition(expr)
+	if err != nil {
+		return 0, fmt.Errorf("%v in %s", err, spellLine(toks))
+	}
+	return val.n, nil
+}
+
+// condValue is a value in an #if expression. As C requires, every signed integer
+// is computed as intmax_t and every unsigned one as uintmax_t, both 64 bits wide
+// here; n holds the bits and unsigned tells which of the two it is.
+type condValue struct {
+	n        int64
+	unsigned bool
+}
+
+// evalCondition evaluates expr, the parsed condition of an #if or #elif directive,
+// in intmax_t and uintmax_t arithmetic. An operation with an unsigned operand is
+// carried out as unsigned, and the right operand of &&, || and the branch of ?:
+// not chosen are not evaluated. Returns an error for division by zero or for an
+// expression that is not an integer constant.
+func evalCondition(expr Expression) (condValue, error) {
+	switch node := expr.(type) {
+	case *IntegerLiteral:
+		return condValue{n: node.Value, unsigned: isUnsignedType(node.Type)}, nil
+	case *CharLiteral:
+		return condValue{n: int64(int8(node.Value))}, nil
+	case *PrefixExpression:
+		right, err := evalCondition(node.Right)
+		if err != nil {
+			return condValue{}, err
+		}
+		switch node.Operator {
+		case "-":
+			return condValue{n: -right.n, unsigned: right.unsigned}, nil
+		case "+":
+			return right, nil
+		case "~":
+			return condValue{n: ^right.n, unsigned: right.unsigned}, nil
+		case "!":
+			return condValue{n: boolToInt(right.n == 0)}, nil
+		}
+	case *InfixExpression:
+		left, err := evalCondition(node.Left)
+		if err != nil {
+			return condValue{}, err
+		}
+		if node.Operator == "&&" && left.n == 0 {
+			return condValue{n: 0}, nil
+		}
+		if node.Operator == "||" && left.n != 0 {
+			return condValue{n: 1}, nil
+		}
+		right, err := evalCondition(node.Right)
+		if err != nil {
+			return condValue{}, err
+		}
+		return condInfix(node.Operator, left, right)
+	case *ConditionalExpression:
+		cond, err := evalCondition(node.Condition)
+		if err != nil {
+			return condValue{}, err
+		}
+		if cond.n != 0 {
+			return evalCondition(node.Consequence)
+		}
+		return evalCondition(node.Alternative)
+	}
+	return condValue{}, errors.New("expression is not an integer constant")
+}
+
+// condInfix applies a binary operator other than && and || to two values of an #if
+// expression. Unless the operator is a shift, the operation is unsigned if either
+// operand is; a shift takes the signedness of its left operand.
+func condInfix(operator string, left, right condValue) (condValue, error) {
+	a, b := left.n, right.n
+	unsigned := left.unsigned || right.unsigned
+	less := func(x, y int64) bool {
+		if unsigned {
+			return uint64(x) < uint64(y)
+		}
+		return x < y
+	}
+
+	switch operator {
+	case "+":
+		return condValue{n: a + b, unsigned: unsigned}, nil
+	case "-":
+		return condValue{n: a - b, unsigned: unsigned}, nil
+	case "*":
+		return condValue{n: a * b, unsigned: unsigned}, nil
+	case "/", "%":
+		if b == 0 {
+			return condValue{}, errors.New("division by zero")
+		}
+		if unsigned {
+			if operator == "/" {
+				return condValue{n: int64(uint64(a) / uint64(b)), unsigned: true}, nil
+			}
+			return condValue{n: int64(uint64(a) % uint64(b)), unsigned: true}, nil
+		}
+		if operator == "/" {
+			return condValue{n: a / b}, nil
+		}
+		return condValue{n: a % b}, nil
+	case "<<":
+		return condValue{n: a << uint64(b), unsigned: left.unsigned}, nil
+	case ">>":
+		if left.unsigned {
+			return condValue{n: int64(uint64(a) >> uint64(b)), unsigned: true}, nil
+		}
+		return condValue{n: a >> uint64(b)}, nil
+	case "&":
+		return condValue{n: a & b, unsigned: unsigned}, nil
+	case "|":
+		return condValue{n: a | b, unsigned: unsigned}, nil
+	case "^":
+		return condValue{n: a ^ b, unsigned: unsigned}, nil
+	case "<":
+		return condValue{n: boolToInt(less(a, b))}, nil
+	case ">":
+		return condValue{n: boolToInt(less(b, a))}, nil
+	case "<=":
+		return condValue{n: boolToInt(!less(b, a))}, nil
+	case ">=":
+		return condValue{n: boolToInt(!less(a, b))}, nil
+	case "==":
+		return condValue{n: boolToInt(a == b)}, nil
+	case "!=":
+		return condValue{n: boolToInt(a != b)}, nil
+	case "&&", "||":
+		// evalCondition has found that the left operand does not decide the result
+		return condValue{n: boolToInt(b != 0)}, nil
+	}
+	return condValue{}, fmt.Errorf("operator %s is not allowed", operator)
+}
+
+// tokenList is a TokenSource that returns the tokens in the list and then EOF.
+type tokenList []Token
+
+// NextToken removes and returns the first token in the list.
+func (t *tokenList) NextToken() Token {
+	if len(*t) == 0 {
+		return Token{Type: EOF}
+	}
+	tok := (*t)[0]
+	*t = (*t)[1:]
+	return tok
+}
+
+// skip discards the lines of a group whose condition is false, stopping before the
+// #elif, #else or #endif that ends it. Conditionals nested in the group are skipped
+// whole, and no other directive in it is carried out. Apart from directives, the
+// lines are skipped as raw text, so they need not be valid C.
+func (pp *Preprocessor) skip() {
+	depth := 0
+	for {
+		if len(pp.pending) == 0 && pp.l != nil {
+			pp.l.skipText()
+		}
+		tok := pp.next()
+		if tok.Type == EOF {
+			pp.unread(tok)
+			return
+		}
+		if tok.Type != HASH || !tok.LineStart {
+			continue
+		}
+
+		if len(pp.pending) == 0 && pp.l != nil && pp.l.atLineEnd() {
+			// The null directive
+			continue
+		}
+		name := pp.next()
+		switch name.Literal {
+		case "if", "ifdef", "ifndef":
+			depth++
+		case "elif", "else", "endif":
+			if depth == 0 {
+				pp.unread(tok, name)
+				return
+			}
+			if name.Literal == "endif" {
+				depth--
+			}
+		}
+	}
+}
+
+// include handles an #include directive whose tokens after the directive name are
+// line, going on to read the named file. The name is either a string literal or a
+// sequence of tokens between < and >; if it is neither, the line is macro-expanded
+// first. A file that has used #pragma once is not read again.
+func (pp *Preprocessor) include(line []Token) error {
+	if len(line) > 0 && line[0].Type != STRING && line[0].Type != LT {
+		line = pp.expandArg(line)
+	}
+
+	var name string
+	var quoted bool
+	switch {
+	case len(line) == 1 && line[0].Type == STRING:
+		name, quoted = line[0].Literal, true
+	case len(line) >= 3 && line[0].Type == LT && line[len(line)-1].Type == GT:
+		name = spellLine(line[1 : len(line)-1])
+	default:
+		return errors.New("#include expects \"FILENAME\" or <FILENAME>")
+	}
+
+	if len(pp.includes) >= maxIncludeDepth {
+		return errors.New("#include nested too deeply")
+	}
+	file, src, err := pp.findInclude(name, quoted)
+	if err != nil {
+		return fmt.Errorf("cannot include %s: %v", name, err)
+	}
+	if pp.once[file] {
+		return nil
+	}
+
+	// The tokens already read belong to this file, so they wait until it resumes
+	pp.includes = append(pp.includes, includeFile{l: pp.l, file: pp.file, pending: pp.pending, conds: pp.conds})
+	pp.l, pp.file, pp.pending, pp.conds = NewLexer(string(src)), file, nil, nil
+	return nil
+}
+
+// findInclude locates the file that #include refers to by name, quoted or in
+// angle brackets, and returns its path and contents.
+func (pp *Preprocessor) findInclude(name string, quoted bool) (string, []byte, error) {
+	var dirs []string
+	if quoted {
+		dirs = append(dirs, path.Dir(pp.file))
+	}
+	dirs = append(dirs, pp.includeDirs...)
+
+	if pp.fsys != nil {
+		for _, dir := range dirs {
+			file := path.Join(dir, name)
+			src, err := fs.ReadFile(pp.fsys, file)
+			if err == nil {
+				return file, src, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrInvalid) {
+				return "", nil, err
+			}
+		}
+	}
+
+	src, err := headers.ReadFile(path.Join("include", name))
+	if err != nil {
+		return "", nil, fmt.Errorf("no such file")
+	}
+	return "<" + name + ">", src, nil
+}
+
+// sameMacro reports whether a and b are identical definitions: the same kind of
+// macro with the same parameters and the same replacement list, spelled the same
+// and with white space in the same places.
+func sameMacro(a, b *macro) bool {
+	if a.funcLike != b.funcLike || !slices.Equal(a.params, b.params) || len(a.body) != len(b.body) {
+		return false
+	}
+	for i := range a.body {
+		if spell(a.body[i]) != spell(b.body[i]) || (i > 0 && a.body[i].Space != b.body[i].Space) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,5 +1,7 @@
 package cint
 
+import "fmt"
+
 // TokenType represents the type of token
 type TokenType int
 
@@ -97,6 +99,9 @@ const (
 	ARROW     // ->
 	QUESTION  // ?
 	COLON     // :
+	ELLIPSIS  // ...
+	HASH      // #
+	HASHHASH  // ##
 )
 
 // keywords is a map that associates C language keyword strings with their corresponding TokenType values.
@@ -144,9 +149,36 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+
+	// Space reports whether white space or a comment came before the token, and
+	// LineStart whether it is the first token on its line. The preprocessor needs
+	// both to recognize directives and function-like macro definitions.
+	Space     bool
+	LineStart bool
+
+	// Raw is the source text of a string or character literal, with its quotes
+	// and escape sequences, whose decoded characters are held in Literal.
+	Raw string
+
+	// File is the path of the included file the token was read from, or "" if it
+	// was read from the main source.
+	File string
+
+	// hide is the set of macro names that must not be expanded again in this
+	// token, because it came from the expansion of those macros.
+	hide []string
 }
 
 
+// Pos returns the position of the token for use in messages: "line N" in the
+// main source, or "file:N" in an included file.
+func (t Token) Pos() string {
+	if t.File == "" {
+		return fmt.Sprintf("line %d", t.Line)
+	}
+	return fmt.Sprintf("%s:%d", t.File, t.Line)
+}
+
 // LookupIdent checks if the provided identifier is a reserved keyword.
 // If the identifier matches a keyword, it returns the corresponding TokenType.
 // Otherwise, it returns IDENT to indicate a user-defined identifier.
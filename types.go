@@ -0,0 +1,585 @@
+package cint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Types are represented throughout the interpreter as strings, the same way the parser
+// records them in VarDecl.Type and Parameter.Type. Derived types are spelled by appending
+// to the element type: "int[10]" is an array of ten ints and "int[3][4]" is an array of
+// three arrays of four ints. An array whose size is not known is written as "int[]".
+// A pointer type ends in '*': "char*" points to a char and "int[4]*" points to an
+// array of four ints. Struct and union types are named by keyword and tag, as in
+// "struct point" or "union value".
+
+// isArrayType reports whether typ describes an array, such as "int[10]" or "char[]".
+func isArrayType(typ string) bool {
+	return strings.HasSuffix(typ, "]")
+}
+
+// arrayElem splits an array type into its element type and its number of elements.
+// For "int[3][4]" it returns "int[4]" and 3. The length is -1 when the array
+// was declared without a size, as in "int[]".
+func arrayElem(typ string) (string, int) {
+	open := outerDim(typ)
+	if open < 0 {
+		return typ, -1
+	}
+	close := strings.IndexByte(typ[open:], ']') + open
+	n, err := strconv.Atoi(typ[open+1 : close])
+	if err != nil {
+		n = -1
+	}
+	return typ[:open] + typ[close+1:], n
+}
+
+// arrayOf returns the type of an array of n elements of type elem. A negative n
+// produces an array of unknown size. The new dimension becomes the outermost one,
+// so arrayOf("int[4]", 3) is "int[3][4]".
+func arrayOf(elem string, n int) string {
+	dim := "[]"
+	if n >= 0 {
+		dim = "[" + strconv.Itoa(n) + "]"
+	}
+	open := outerDim(elem)
+	if open < 0 {
+		return elem + dim
+	}
+	return elem[:open] + dim + elem[open:]
+}
+
+// outerDim returns the index of the '[' that opens the outermost array dimension
+// of typ, or -1 if typ is not an array. Dimensions written before a '*' belong to
+// the pointed-to type, so only the part after the last '*' is searched.
+func outerDim(typ string) int {
+	start := strings.LastIndexByte(typ, '*') + 1
+	open := strings.IndexByte(typ[start:], '[')
+	if open < 0 {
+		return -1
+	}
+	return start + open
+}
+
+// isPointerType reports whether typ describes a pointer, such as "int*".
+func isPointerType(typ string) bool {
+	return strings.HasSuffix(typ, "*")
+}
+
+// pointerElem returns the type a pointer type points to, so "char**" yields "char*".
+func pointerElem(typ string) string {
+	return strings.TrimSuffix(typ, "*")
+}
+
+// isStructType reports whether typ names a struct or union type.
+func isStructType(typ string) bool {
+	return (strings.HasPrefix(typ, "struct ") || strings.HasPrefix(typ, "union ")) &&
+		!strings.ContainsAny(typ, "*[")
+}
+
+// intType describes the width in bytes and the signedness of an integer type.
+type intType struct {
+	size     int
+	unsigned bool
+}
+
+// integerTypes lists the integer types by the names the parser gives them. Widths
+// follow the LP64 model used by 64-bit Unix systems: char is a signed byte, short is
+// two bytes, int four, and long eight; long long is the same type as long.
+var integerTypes = map[string]intType{
+	"char":           {1, false},
+	"unsigned char":  {1, true},
+	"short":          {2, false},
+	"unsigned short": {2, true},
+	"int":            {4, false},
+	"unsigned int":   {4, true},
+	"long":           {8, false},
+	"unsigned long":  {8, true},
+}
+
+// isIntegerType reports whether typ is one of the integer types, such as "int" or "unsigned char".
+func isIntegerType(typ string) bool {
+	_, ok := integerTypes[typ]
+	return ok
+}
+
+// isUnsignedType reports whether typ is an unsigned integer type.
+func isUnsignedType(typ string) bool {
+	return integerTypes[typ].unsigned
+}
+
+// convertInt converts n to the integer type typ, keeping only as many low-order bits
+// as the type holds and then sign- or zero-extending the result back to 64 bits, so an
+// unsigned char holds 0 to 255 and an int wraps around at 32 bits. Values of 64-bit
+// types, including pointers, are returned unchanged; an unsigned long keeps its bit
+// pattern in the int64.
+func convertInt(n int64, typ string) int64 {
+	t, ok := integerTypes[typ]
+	if !ok {
+		return n
+	}
+	switch {
+	case t.size == 1 && t.unsigned:
+		return int64(uint8(n))
+	case t.size == 1:
+		return int64(int8(n))
+	case t.size == 2 && t.unsigned:
+		return int64(uint16(n))
+	case t.size == 2:
+		return int64(int16(n))
+	case t.size == 4 && t.unsigned:
+		return int64(uint32(n))
+	case t.size == 4:
+		return int64(int32(n))
+	}
+	return n
+}
+
+// fitsType reports whether the non-negative value n can be represented in the integer type typ.
+func fitsType(n uint64, typ string) bool {
+	t := integerTypes[typ]
+	bits := t.size * 8
+	if !t.unsigned {
+		bits--
+	}
+	return bits >= 64 || n < 1<<bits
+}
+
+// promote applies the integer promotions to typ: integer types narrower than int are
+// promoted to int, which can represent all of their values. Other types are returned
+// unchanged.
+func promote(typ string) string {
+	if t, ok := integerTypes[typ]; ok && t.size < 4 {
+		return "int"
+	}
+	return typ
+}
+
+// commonIntType returns the type that the usual arithmetic conversions bring two
+// integer operands to. Both are promoted first. If they then differ, the wider type
+// wins; between types of equal width, the unsigned one wins. Operands that are not
+// integers, such as pointers compared against each other, are treated as long.
+func commonIntType(a, b string) string {
+	a, b = promote(a), promote(b)
+	if !isIntegerType(a) {
+		a = "long"
+	}
+	if !isIntegerType(b) {
+		b = "long"
+	}
+	if a == b {
+		return a
+	}
+
+	ta, tb := integerTypes[a], integerTypes[b]
+	if ta.size != tb.size {
+		if ta.size > tb.size {
+			return a
+		}
+		return b
+	}
+	if ta.unsigned {
+		return a
+	}
+	return b
+}
+
+// convert returns val converted to typ as if by assignment, as happens when a function
+// returns a value of a different type than it is declared with. Floating-point values
+// are truncated towards zero when converted to an integer type, a value converted to
+// float is rounded to float precision, and integers are
+// narrowed or widened as described for convertInt. A pointer keeps its address under
+// the new type, and struct values are returned unchanged.
+func convert(val *Value, typ string) *Value {
+	switch {
+	case isFloatType(typ):
+		return &Value{Type: typ, Float: roundFloat(floatValue(val), typ)}
+	case isIntegerType(typ):
+		return &Value{Type: typ, Int: convertInt(intValue(val, typ), typ)}
+	case isPointerType(typ):
+		return &Value{Type: typ, Int: val.Int}
+	}
+	return val
+}
+
+// floatValue returns the value of val as a float64. An integer of an unsigned type
+// is read as unsigned, so an unsigned long of 2^63 or more stays positive.
+func floatValue(val *Value) float64 {
+	switch {
+	case isFloatType(val.Type):
+		return val.Float
+	case isUnsignedType(val.Type):
+		return float64(uint64(val.Int))
+	}
+	return float64(val.Int)
+}
+
+// intValue returns the value of val for conversion to the integer type typ, to which
+// the caller still has to narrow it. A floating value is truncated toward zero, and
+// through uint64 if typ is unsigned, so that values of 2^63 or more survive.
+func intValue(val *Value, typ string) int64 {
+	switch {
+	case !isFloatType(val.Type):
+		return val.Int
+	case isUnsignedType(typ) && val.Float >= 0:
+		return int64(uint64(val.Float))
+	}
+	return int64(val.Float)
+}
+
+// isCharArray reports whether typ is an array of one of the char types, which can be
+// initialized from a string literal.
+func isCharArray(typ string) bool {
+	if !isArrayType(typ) {
+		return false
+	}
+	elem, _ := arrayElem(typ)
+	return isIntegerType(elem) && integerTypes[elem].size == 1
+}
+
+// isFloatType reports whether typ is one of the floating-point types.
+func isFloatType(typ string) bool {
+	return typ == "float" || typ == "double"
+}
+
+// roundFloat rounds f to the precision of the floating type typ: a float keeps
+// only the precision of a float32, while a double keeps all of f.
+func roundFloat(f float64, typ string) float64 {
+	if typ == "float" {
+		return float64(float32(f))
+	}
+	return f
+}
+
+// sizeOf returns the storage size in bytes of a value of the given type.
+// It returns -1 for types that have no known size, such as "void" or an
+// array declared without a length.
+func (i *Interpreter) sizeOf(typ string) int {
+	if isPointerType(typ) {
+		return 8
+	}
+	if isArrayType(typ) {
+		elem, n := arrayElem(typ)
+		elemSize := i.sizeOf(elem)
+		if n < 0 || elemSize < 0 {
+			return -1
+		}
+		return n * elemSize
+	}
+	if isStructType(typ) {
+		layout, err := i.structLayout(typ)
+		if err != nil {
+			return -1
+		}
+		return layout.size
+	}
+
+	return scalarSize(typ)
+}
+
+// scalarSize returns the size in bytes of a pointer, integer or floating type,
+// or -1 for any other type.
+func scalarSize(typ string) int {
+	if isPointerType(typ) {
+		return 8
+	}
+	if t, ok := integerTypes[typ]; ok {
+		return t.size
+	}
+	switch typ {
+	case "float":
+		return 4
+	case "double":
+		return 8
+	}
+	return -1
+}
+
+// alignOf returns the alignment in bytes required for a value of the given type.
+func (i *Interpreter) alignOf(typ string) int {
+	for isArrayType(typ) {
+		typ, _ = arrayElem(typ)
+	}
+	if isStructType(typ) {
+		if layout, err := i.structLayout(typ); err == nil {
+			return layout.align
+		}
+		return 1
+	}
+	if size := i.sizeOf(typ); size > 0 {
+		return size
+	}
+	return 1
+}
+
+// fieldLayout records the type and byte offset of a struct or union member.
+type fieldLayout struct {
+	Type   string
+	Offset int
+}
+
+// layout describes how a struct or union is arranged in memory: where each member
+// lives, the total size including padding, and the alignment of the whole.
+type layout struct {
+	fields map[string]fieldLayout
+	size   int
+	align  int
+}
+
+// structLayout returns the memory layout of the struct or union type typ, computing
+// it on first use. Struct members are placed in declaration order, each aligned to its
+// own alignment, and the size is rounded up to the strictest member alignment. All
+// members of a union share offset zero. Returns an error if the type has not been
+// defined or contains a member of unknown size.
+func (i *Interpreter) structLayout(typ string) (*layout, error) {
+	if l, ok := i.layouts[typ]; ok {
+		if l == nil {
+			return nil, fmt.Errorf("%s contains itself", typ)
+		}
+		return l, nil
+	}
+
+	decl, ok := i.structs[typ]
+	if !ok {
+		return nil, fmt.Errorf("incomplete type %s", typ)
+	}
+	i.layouts[typ] = nil // mark as in progress
+
+	l := &layout{fields: make(map[string]fieldLayout), align: 1}
+	offset := 0
+	for _, field := range decl.Fields {
+		size := i.sizeOf(field.Type)
+		if size < 0 {
+			delete(i.layouts, typ)
+			return nil, fmt.Errorf("member %s of %s has incomplete type %s", field.Name, typ, field.Type)
+		}
+		align := i.alignOf(field.Type)
+		if align > l.align {
+			l.align = align
+		}
+
+		if decl.Kind == "union" {
+			l.fields[field.Name] = fieldLayout{Type: field.Type, Offset: 0}
+			if size > l.size {
+				l.size = size
+			}
+			continue
+		}
+
+		offset = (offset + align - 1) / align * align
+		l.fields[field.Name] = fieldLayout{Type: field.Type, Offset: offset}
+		offset += size
+		l.size = offset
+	}
+	l.size = (l.size + l.align - 1) / l.align * l.align
+
+	i.layouts[typ] = l
+	return l, nil
+}
+
+// builtinTypes records the return type of each built-in function, which typeOf
+// needs to know without calling the function.
+var builtinTypes = map[string]string{
+	"printf":  "int",
+	"sleep":   "int",
+	"putchar": "int",
+	"malloc":  "void*",
+	"calloc":  "void*",
+	"realloc": "void*",
+	"free":    "void",
+	"sqrt":    "double",
+	"pow":     "double",
+	"sin":     "double",
+	"cos":     "double",
+	"tan":     "double",
+	"abs":     "int",
+	"floor":   "double",
+	"ceil":    "double",
+	"log":     "double",
+	"log10":   "double",
+	"exp":     "double",
+}
+
+// decayType returns the type an expression of type typ has when used as a value:
+// an array becomes a pointer to its first element and other types are unchanged.
+func decayType(typ string) string {
+	if isArrayType(typ) {
+		elem, _ := arrayElem(typ)
+		return elem + "*"
+	}
+	return typ
+}
+
+// typeOf returns the type of expr without evaluating it, as sizeof requires. The
+// result follows the same rules the interpreter applies when it evaluates the
+// expression, except that an array named directly, as in sizeof a, keeps its array
+// type rather than decaying to a pointer. A character constant has type int, as in C.
+// Returns an error if expr refers to an undefined variable or member.
+func (i *Interpreter) typeOf(expr Expression, env *Environment) (string, error) {
+	switch node := expr.(type) {
+	case *IntegerLiteral:
+		return node.Type, nil
+	case *FloatLiteral:
+		return node.Type, nil
+	case *CharLiteral:
+		return "int", nil
+	case *StringLiteral:
+		return arrayOf("char", len(node.Value)+1), nil
+	case *Identifier:
+		v, ok := env.Get(node.Value)
+		if !ok {
+			return "", fmt.Errorf("undefined variable: %s", node.Value)
+		}
+		return v.Type, nil
+	case *SizeofExpression:
+		return "unsigned long", nil
+	case *CastExpression:
+		return node.Type, nil
+	case *AssignmentExpression:
+		return i.typeOf(node.Left, env)
+	case *PrefixExpression:
+		return i.typeOfPrefix(node, env)
+	case *PostfixExpression:
+		return i.typeOf(node.Left, env)
+	case *InfixExpression:
+		return i.typeOfInfix(node, env)
+	case *ConditionalExpression:
+		typ, err := i.typeOf(node.Consequence, env)
+		if err != nil {
+			return "", err
+		}
+		alt, err := i.typeOf(node.Alternative, env)
+		if err != nil {
+			return "", err
+		}
+		return arithmeticType(decayType(typ), decayType(alt)), nil
+	case *ArrayExpression:
+		base, err := i.typeOf(node.Left, env)
+		if err != nil {
+			return "", err
+		}
+		index, err := i.typeOf(node.Index, env)
+		if err != nil {
+			return "", err
+		}
+		base, index = decayType(base), decayType(index)
+		if isPointerType(index) {
+			base = index
+		}
+		if !isPointerType(base) {
+			return "", fmt.Errorf("subscripted value is not an array or pointer: %s", node.Left.String())
+		}
+		return pointerElem(base), nil
+	case *MemberExpression:
+		typ, err := i.typeOf(node.Left, env)
+		if err != nil {
+			return "", err
+		}
+		if node.Operator == "->" {
+			typ = pointerElem(decayType(typ))
+		}
+		if !isStructType(typ) {
+			return "", fmt.Errorf("request for member %s in something not a struct or union", node.Member)
+		}
+		layout, err := i.structLayout(typ)
+		if err != nil {
+			return "", err
+		}
+		field, ok := layout.fields[node.Member]
+		if !ok {
+			return "", fmt.Errorf("%s has no member named %s", typ, node.Member)
+		}
+		return field.Type, nil
+	case *CallExpression:
+		ident, ok := node.Function.(*Identifier)
+		if !ok {
+			return "", fmt.Errorf("invalid function call")
+		}
+		if typ, ok := builtinTypes[ident.Value]; ok && !i.defines(ident.Value) {
+			return typ, nil
+		}
+		if fn, ok := i.functions[ident.Value]; ok {
+			return fn.ReturnType, nil
+		}
+		// As in K&R C, a function that has not been declared returns int
+		return "int", nil
+	}
+	return "", fmt.Errorf("cannot determine the type of %s", expr.String())
+}
+
+// typeOfPrefix returns the type of a prefix expression without evaluating it.
+func (i *Interpreter) typeOfPrefix(node *PrefixExpression, env *Environment) (string, error) {
+	typ, err := i.typeOf(node.Right, env)
+	if err != nil {
+		return "", err
+	}
+
+	switch node.Operator {
+	case "&":
+		return typ + "*", nil
+	case "++", "--":
+		return typ, nil
+	case "!":
+		return "int", nil
+	case "*":
+		typ = decayType(typ)
+		if !isPointerType(typ) {
+			return "", fmt.Errorf("cannot dereference non-pointer value of type %s", typ)
+		}
+		return pointerElem(typ), nil
+	}
+	if isFloatType(typ) {
+		return typ, nil
+	}
+	return commonIntType(typ, typ), nil
+}
+
+// typeOfInfix returns the type of a binary expression without evaluating it.
+func (i *Interpreter) typeOfInfix(node *InfixExpression, env *Environment) (string, error) {
+	left, err := i.typeOf(node.Left, env)
+	if err != nil {
+		return "", err
+	}
+	right, err := i.typeOf(node.Right, env)
+	if err != nil {
+		return "", err
+	}
+	left, right = decayType(left), decayType(right)
+
+	switch node.Operator {
+	case ",":
+		return right, nil
+	case "&&", "||", "==", "!=", "<", ">", "<=", ">=":
+		return "int", nil
+	case "<<", ">>":
+		return promote(left), nil
+	case "+", "-":
+		switch {
+		case isPointerType(left) && isPointerType(right):
+			return "long", nil
+		case isPointerType(left):
+			return left, nil
+		case isPointerType(right):
+			return right, nil
+		}
+	}
+	return arithmeticType(left, right), nil
+}
+
+// arithmeticType returns the type the usual arithmetic conversions give two operands:
+// double if either is a double, float if either is a float, and otherwise their
+// common integer type. A pointer or struct operand is returned as it is.
+func arithmeticType(a, b string) string {
+	switch {
+	case a == "double" || b == "double":
+		return "double"
+	case a == "float" || b == "float":
+		return "float"
+	case isIntegerType(a) && isIntegerType(b):
+		return commonIntType(a, b)
+	case !isIntegerType(a):
+		return a
+	}
+	return b
+}